@@ -0,0 +1,216 @@
+// Package book implements reading and writing of opening books in the Polyglot
+// format.
+//
+// See http://hgm.nubati.net/book_format.html.
+package book
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"os"
+
+	"github.com/treepeck/chego"
+)
+
+// entrySize is the size of a single book entry in bytes.
+const entrySize = 16
+
+var (
+	// ErrNotFound is returned when the book has no moves for the position.
+	ErrNotFound = errors.New("position not found in the book")
+	// ErrInvalidBook is returned when the size of the book file is not a
+	// multiple of the entry size.
+	ErrInvalidBook = errors.New("invalid book size")
+)
+
+// Entry represents a single book entry.  Entries are stored sorted by key, so
+// all the moves of the position are adjacent.
+type Entry struct {
+	// Zobrist key of the position, see [chego.Position.ZobristKey].
+	Key uint64
+	// Move encoded in the Polyglot format, see [DecodeMove].
+	Move uint16
+	// Weight defines how good the move is.  Higher is better.
+	Weight uint16
+	// Learn is not used by the Polyglot itself and is usually zero.
+	Learn uint32
+}
+
+// Book provides access to the Polyglot book.  Entries are read on demand, so
+// the book is not loaded into memory.
+type Book struct {
+	r    io.ReaderAt
+	size int64
+	// Underlying file, if the book has been opened with [Open].
+	f *os.File
+}
+
+// New creates a new book which reads size bytes of entries from r.
+func New(r io.ReaderAt, size int64) (*Book, error) {
+	if size%entrySize != 0 {
+		return nil, ErrInvalidBook
+	}
+	return &Book{r: r, size: size}, nil
+}
+
+// Open opens the book file with the specified name.  Close must be called when
+// the book is no longer used.
+func Open(name string) (*Book, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	b, err := New(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.f = f
+	return b, nil
+}
+
+// Close closes the underlying file, if any.
+func (b *Book) Close() error {
+	if b.f == nil {
+		return nil
+	}
+	return b.f.Close()
+}
+
+// Len returns the number of entries in the book.
+func (b *Book) Len() int {
+	return int(b.size / entrySize)
+}
+
+// entry reads the entry with the specified index.
+func (b *Book) entry(i int) (Entry, error) {
+	var buf [entrySize]byte
+	if _, err := b.r.ReadAt(buf[:], int64(i)*entrySize); err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Key:    binary.BigEndian.Uint64(buf[0:8]),
+		Move:   binary.BigEndian.Uint16(buf[8:10]),
+		Weight: binary.BigEndian.Uint16(buf[10:12]),
+		Learn:  binary.BigEndian.Uint32(buf[12:16]),
+	}, nil
+}
+
+// Entries returns all entries with the specified key.  Binary search is used to
+// find the first entry.
+func (b *Book) Entries(key uint64) ([]Entry, error) {
+	lo, hi := 0, b.Len()
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		e, err := b.entry(mid)
+		if err != nil {
+			return nil, err
+		}
+		if e.Key < key {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+
+	entries := make([]Entry, 0)
+	for i := lo; i < b.Len(); i++ {
+		e, err := b.entry(i)
+		if err != nil {
+			return nil, err
+		}
+		if e.Key != key {
+			break
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Move represents a legal book move along with its weight.
+type Move struct {
+	Move   chego.Move
+	Weight uint16
+}
+
+// Moves returns the legal book moves for the specified position.  Moves which
+// cannot be decoded in the position are skipped.  Returns [ErrNotFound] if the
+// book has no moves for the position.
+func (b *Book) Moves(p *chego.Position) ([]Move, error) {
+	entries, err := b.Entries(p.ZobristKey())
+	if err != nil {
+		return nil, err
+	}
+
+	moves := make([]Move, 0, len(entries))
+	for _, e := range entries {
+		m, err := DecodeMove(e.Move, p)
+		if err != nil {
+			continue
+		}
+		moves = append(moves, Move{Move: m, Weight: e.Weight})
+	}
+
+	if len(moves) == 0 {
+		return nil, ErrNotFound
+	}
+	return moves, nil
+}
+
+// BestMove returns the book move with the highest weight.
+func (b *Book) BestMove(p *chego.Position) (chego.Move, error) {
+	moves, err := b.Moves(p)
+	if err != nil {
+		return 0, err
+	}
+
+	best := moves[0]
+	for _, m := range moves[1:] {
+		if m.Weight > best.Weight {
+			best = m
+		}
+	}
+	return best.Move, nil
+}
+
+// RandomMove picks the book move randomly.  The probability of each move being
+// picked is proportional to its weight.  If all moves have zero weight, they
+// are picked uniformly.  r is used as the source of randomness; if nil, the
+// global source is used.
+func (b *Book) RandomMove(p *chego.Position, r *rand.Rand) (chego.Move, error) {
+	moves, err := b.Moves(p)
+	if err != nil {
+		return 0, err
+	}
+
+	intN := rand.IntN
+	if r != nil {
+		intN = r.IntN
+	}
+
+	total := 0
+	for _, m := range moves {
+		total += int(m.Weight)
+	}
+	if total == 0 {
+		return moves[intN(len(moves))].Move, nil
+	}
+
+	n := intN(total)
+	for _, m := range moves {
+		n -= int(m.Weight)
+		if n < 0 {
+			return m.Move, nil
+		}
+	}
+	return moves[len(moves)-1].Move, nil
+}
@@ -0,0 +1,112 @@
+package book
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+// newTestBook builds the book from the specified games.
+func newTestBook(t *testing.T, games [][]string, results []chego.Result) *Book {
+	b := NewBuilder()
+	for i, moves := range games {
+		g := chego.NewGame(chego.InitialPos)
+		for _, san := range moves {
+			if err := g.PushSAN(san); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b.AddGame(g, results[i])
+	}
+
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	book, err := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestBook(t *testing.T) {
+	book := newTestBook(t, [][]string{
+		{"e4", "e5", "Nf3"},
+		{"e4", "c5"},
+		{"d4", "d5"},
+		{"e4", "e5", "Nf3"},
+	}, []chego.Result{
+		chego.ResultWhiteWon, chego.ResultDraw, chego.ResultBlackWon,
+		chego.ResultUnknown,
+	})
+
+	if book.Len() != 6 {
+		t.Fatalf("expected 6 entries, got %d", book.Len())
+	}
+
+	p := chego.ParseFen(chego.InitialPos)
+	moves, err := book.Moves(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// e4 scored 2 + 1 + 1, d4 scored 0.
+	if len(moves) != 2 || moves[0].Weight != 4 || moves[1].Weight != 0 {
+		t.Fatalf("unexpected moves: %v", moves)
+	}
+
+	best, err := book.BestMove(p)
+	if err != nil || best != chego.NewMove(chego.SE4, chego.SE2, chego.MoveNormal) {
+		t.Fatalf("unexpected best move: %d %v", best, err)
+	}
+
+	// d4 has zero weight, so it is never picked.
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		m, err := book.RandomMove(p, r)
+		if err != nil || m != best {
+			t.Fatalf("unexpected random move: %d %v", m, err)
+		}
+	}
+
+	p = chego.ParseFen("rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	if _, err = book.Moves(p); err != nil {
+		t.Fatal(err)
+	}
+
+	p = chego.ParseFen("rnbqkbnr/pppp1ppp/8/4p3/3P4/8/PPP1PPPP/RNBQKBNR w KQkq - 0 2")
+	if _, err = book.Moves(p); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestNewInvalidSize(t *testing.T) {
+	if _, err := New(bytes.NewReader(make([]byte, 17)), 17); err != ErrInvalidBook {
+		t.Fatalf("expected ErrInvalidBook, got %v", err)
+	}
+}
+
+func BenchmarkBestMove(b *testing.B) {
+	builder := NewBuilder()
+	g := chego.NewGame(chego.InitialPos)
+	for range 10 {
+		for i := range g.LegalMoves.Len {
+			builder.AddGame(&chego.Game{
+				StartFEN: chego.InitialPos,
+				Moves:    []chego.PlayedMove{{Move: g.LegalMoves.Moves[i]}},
+			}, chego.ResultDraw)
+		}
+	}
+
+	var buf bytes.Buffer
+	builder.WriteTo(&buf)
+	book, _ := New(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	p := chego.ParseFen(chego.InitialPos)
+
+	for b.Loop() {
+		book.BestMove(p)
+	}
+}
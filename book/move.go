@@ -0,0 +1,82 @@
+// move.go implements conversion between chego moves and Polyglot moves.
+
+package book
+
+import "github.com/treepeck/chego"
+
+// Polyglot move is encoded as a 16 bit unsigned integer:
+//   - 0-2:   To file.
+//   - 3-5:   To row.
+//   - 6-8:   From file.
+//   - 9-11:  From row.
+//   - 12-14: Promotion piece: 0 - none, 1 - knight, 2 - bishop, 3 - rook,
+//     4 - queen.
+//
+// Castling is encoded as the king capturing its own rook, e.g. e1h1 for white
+// O-O.  Since the square layout is the same as in chego, the square indices can
+// be used directly.  Polyglot books are defined for the standard chess only, so
+// Chess960 castling moves are not supported.
+
+// DecodeMove converts the Polyglot move into the legal move in the specified
+// position.  Returns [chego.ErrIllegalMove] if there is no such legal move,
+// which may indicate a hash collision.
+func DecodeMove(raw uint16, p *chego.Position) (chego.Move, error) {
+	to := int(raw & 0x3F)
+	from := int(raw>>6) & 0x3F
+	promo := int(raw>>12)&0x7 - 1
+
+	var legal chego.MoveList
+	chego.GenLegalMoves(*p, &legal)
+
+	for i := range legal.Len {
+		m := legal.Moves[i]
+		if m.From() != from {
+			continue
+		}
+
+		switch m.Type() {
+		case chego.MoveCastling:
+			// Some books encode castling as the king's destination square.
+			if castlingRook(m) == to || m.To() == to {
+				return m, nil
+			}
+		case chego.MovePromotion:
+			if m.To() == to && m.PromoPiece() == promo {
+				return m, nil
+			}
+		default:
+			if m.To() == to && promo == -1 {
+				return m, nil
+			}
+		}
+	}
+
+	return 0, chego.ErrIllegalMove
+}
+
+// EncodeMove converts the move into the Polyglot format.
+func EncodeMove(m chego.Move) uint16 {
+	raw := uint16(m.To() | m.From()<<6)
+
+	switch m.Type() {
+	case chego.MoveCastling:
+		raw = uint16(castlingRook(m) | m.From()<<6)
+	case chego.MovePromotion:
+		raw |= uint16(m.PromoPiece()+1) << 12
+	}
+	return raw
+}
+
+// castlingRook returns the initial square of the rook which takes part in the
+// castling.
+func castlingRook(m chego.Move) int {
+	switch m.To() {
+	case chego.SG1:
+		return chego.SH1
+	case chego.SC1:
+		return chego.SA1
+	case chego.SG8:
+		return chego.SH8
+	}
+	return chego.SA8
+}
@@ -0,0 +1,62 @@
+package book
+
+import (
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestDecodeMove(t *testing.T) {
+	cases := []struct {
+		raw      uint16
+		fen      string
+		expected chego.Move
+	}{
+		// e2e4.
+		{796, chego.InitialPos, chego.NewMove(chego.SE4, chego.SE2, chego.MoveNormal)},
+		// e1h1.
+		{
+			uint16(chego.SH1 | chego.SE1<<6),
+			"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			chego.NewMove(chego.SG1, chego.SE1, chego.MoveCastling),
+		},
+		// e8a8.
+		{
+			uint16(chego.SA8 | chego.SE8<<6),
+			"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+			chego.NewMove(chego.SC8, chego.SE8, chego.MoveCastling),
+		},
+		// e1g1.
+		{
+			uint16(chego.SG1 | chego.SE1<<6),
+			"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			chego.NewMove(chego.SG1, chego.SE1, chego.MoveCastling),
+		},
+		// b7b8n.
+		{
+			uint16(chego.SB8 | chego.SB7<<6 | 1<<12),
+			"4k3/1P6/8/8/8/8/8/4K3 w - - 0 1",
+			chego.NewPromotionMove(chego.SB8, chego.SB7, chego.PromotionKnight),
+		},
+	}
+
+	for _, tc := range cases {
+		got, err := DecodeMove(tc.raw, chego.ParseFen(tc.fen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.expected {
+			t.Fatalf("expected %d, got %d", tc.expected, got)
+		}
+		// e1g1 is encoded as e1h1.
+		if tc.raw != uint16(chego.SG1|chego.SE1<<6) && EncodeMove(got) != tc.raw {
+			t.Fatalf("expected %d, got %d", tc.raw, EncodeMove(got))
+		}
+	}
+
+	// Promotion without the promotion piece.
+	_, err := DecodeMove(uint16(chego.SB8|chego.SB7<<6), chego.ParseFen("4k3/1P6/8/8/8/8/8/4K3 w - - 0 1"))
+	if err != chego.ErrIllegalMove {
+		t.Fatalf("expected ErrIllegalMove, got %v", err)
+	}
+}
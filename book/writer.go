@@ -0,0 +1,127 @@
+// writer.go implements building of Polyglot books from played games.
+
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"slices"
+
+	"github.com/treepeck/chego"
+)
+
+// entryKey identifies the book entry while the book is being built.
+type entryKey struct {
+	key  uint64
+	move uint16
+}
+
+// Builder accumulates the moves of the played games and writes them as the
+// Polyglot book.
+//
+// The weight of each move is based on the results of the games in which it was
+// played: 2 points for a win of the side which played the move, 1 point for a
+// draw or an unknown result, and 0 points for a loss.
+type Builder struct {
+	// MaxPly limits the number of half-moves of each game added to the book.
+	// Zero means no limit.
+	MaxPly int
+	scores map[entryKey]uint64
+}
+
+// NewBuilder creates an empty book builder.
+func NewBuilder() *Builder {
+	return &Builder{scores: make(map[entryKey]uint64)}
+}
+
+// AddGame adds the moves of the game to the book.  The game result is passed
+// separately, since games may end by resignation or on time, which cannot be
+// detected from the position.
+func (b *Builder) AddGame(g *chego.Game, result chego.Result) {
+	replay := chego.NewGame(g.StartFEN)
+
+	for ply, played := range g.Moves {
+		if b.MaxPly > 0 && ply >= b.MaxPly {
+			break
+		}
+
+		score := uint64(1)
+		switch {
+		case result == chego.ResultWhiteWon &&
+			replay.Position.ActiveColor == chego.ColorWhite,
+			result == chego.ResultBlackWon &&
+				replay.Position.ActiveColor == chego.ColorBlack:
+			score = 2
+		case result == chego.ResultWhiteWon, result == chego.ResultBlackWon:
+			score = 0
+		}
+
+		k := entryKey{replay.Position.ZobristKey(), EncodeMove(played.Move)}
+		if replay.Push(played.Move) != nil {
+			break
+		}
+		// Losing moves are kept in the book with zero weight.
+		b.scores[k] += score
+	}
+}
+
+// Entries returns the accumulated book entries sorted by key and descending
+// weight.  Weights are scaled down proportionally if they do not fit into 16
+// bits.
+func (b *Builder) Entries() []Entry {
+	var highest uint64
+	for _, score := range b.scores {
+		highest = max(highest, score)
+	}
+
+	entries := make([]Entry, 0, len(b.scores))
+	for k, score := range b.scores {
+		if highest > 0xFFFF {
+			score = score * 0xFFFF / highest
+		}
+		entries = append(entries, Entry{
+			Key: k.key, Move: k.move, Weight: uint16(score),
+		})
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		switch {
+		case a.Key < b.Key:
+			return -1
+		case a.Key > b.Key:
+			return 1
+		case a.Weight != b.Weight:
+			return int(b.Weight) - int(a.Weight)
+		}
+		return int(a.Move) - int(b.Move)
+	})
+	return entries
+}
+
+// WriteTo writes the accumulated book entries into w.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	return Write(w, b.Entries())
+}
+
+// Write writes the entries into w in the Polyglot format.  It's the caller's
+// responsibility to sort the entries by key.
+func Write(w io.Writer, entries []Entry) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+
+	var buf [entrySize]byte
+	for _, e := range entries {
+		binary.BigEndian.PutUint64(buf[0:8], e.Key)
+		binary.BigEndian.PutUint16(buf[8:10], e.Move)
+		binary.BigEndian.PutUint16(buf[10:12], e.Weight)
+		binary.BigEndian.PutUint32(buf[12:16], e.Learn)
+
+		written, err := bw.Write(buf[:])
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
@@ -0,0 +1,80 @@
+// chess960.go implements generation of the Chess960 (Fischer Random Chess)
+// starting positions.
+
+package chego
+
+import "strings"
+
+// knightPlacements maps the knight index of the Scharnagl numbering scheme to
+// the pair of empty squares occupied by knights, counting from the a-file.
+var knightPlacements = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2},
+	{1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// Chess960FEN returns the FEN of the Chess960 starting position with the
+// specified index between 0 and 959, as numbered by the Scharnagl scheme.  The
+// index 518 denotes the standard initial position.
+//
+// Castling rights are written in Shredder-FEN, e.g. "HAha", so [ParseFen]
+// enables [Position.Chess960] for all of the starting positions.  Panics if the
+// index is out of range.
+func Chess960FEN(index int) string {
+	if index < 0 || index >= 960 {
+		panic("Chess960 position index out of range")
+	}
+
+	var rank [8]byte
+	// Light-squared bishop occupies the b, d, f, or h file, while the
+	// dark-squared one occupies the a, c, e, or g file.
+	rank[2*(index%4)+1] = 'B'
+	index /= 4
+	rank[2*(index%4)] = 'B'
+	index /= 4
+
+	placeEmpty(&rank, index%6, 'Q')
+	index /= 6
+
+	// Place the second knight first to keep the empty squares of the first
+	// one intact.
+	placeEmpty(&rank, knightPlacements[index][1], 'N')
+	placeEmpty(&rank, knightPlacements[index][0], 'N')
+
+	// The king stands between the rooks on the remaining squares.
+	placeEmpty(&rank, 0, 'R')
+	placeEmpty(&rank, 0, 'K')
+	placeEmpty(&rank, 0, 'R')
+
+	white := string(rank[:])
+
+	var b strings.Builder
+	b.Grow(64)
+	b.WriteString(strings.ToLower(white))
+	b.WriteString("/pppppppp/8/8/8/8/PPPPPPPP/")
+	b.WriteString(white)
+	b.WriteString(" w ")
+	castling := string([]byte{
+		'A' + byte(strings.LastIndexByte(white, 'R')),
+		'A' + byte(strings.IndexByte(white, 'R')),
+	})
+	b.WriteString(castling)
+	b.WriteString(strings.ToLower(castling))
+	b.WriteString(" - 0 1")
+
+	return b.String()
+}
+
+// placeEmpty places the piece on the n-th empty square of the rank, counting
+// from the a-file.
+func placeEmpty(rank *[8]byte, n int, piece byte) {
+	for i := range rank {
+		if rank[i] != 0 {
+			continue
+		}
+		if n == 0 {
+			rank[i] = piece
+			return
+		}
+		n--
+	}
+}
@@ -0,0 +1,135 @@
+package chego
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChess960FEN(t *testing.T) {
+	cases := []struct {
+		index    int
+		expected string
+	}{
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+		{518, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w CAca - 0 1"},
+	}
+
+	for _, tc := range cases {
+		if got := Chess960FEN(tc.index); got != tc.expected {
+			t.Fatalf("%d: expected %s, got %s", tc.index, tc.expected, got)
+		}
+	}
+
+	seen := make(map[string]bool, 960)
+	for i := range 960 {
+		fen := Chess960FEN(i)
+		placement := strings.SplitN(fen, "/", 2)[0]
+		if seen[placement] {
+			t.Fatalf("%d: duplicate position %s", i, fen)
+		}
+		seen[placement] = true
+
+		p, err := ParseFENStrict(fen)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !p.Chess960 || p.CastlingRights != 0xF {
+			t.Fatalf("%d: expected Chess960 with all castling rights", i)
+		}
+		if got := SerializeFen(p); got != fen {
+			t.Fatalf("%d: expected %s, got %s", i, fen, got)
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	cases := []struct {
+		name     string
+		fen      string
+		expected []Move
+	}{
+		{
+			"king and rook swap",
+			"4k3/8/8/8/8/8/8/5KR1 w G - 0 1",
+			[]Move{NewMove(SG1, SF1, MoveCastling)},
+		},
+		{
+			"king on its destination",
+			"1rk5/8/8/8/8/8/8/4K3 b b - 0 1",
+			[]Move{NewMove(SB8, SC8, MoveCastling)},
+		},
+		{
+			"both sides",
+			"4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1",
+			[]Move{NewMove(SG1, SF1, MoveCastling), NewMove(SB1, SF1, MoveCastling)},
+		},
+		{
+			"rook destination is occupied",
+			"4k3/8/8/8/8/8/8/RN2K3 w A - 0 1",
+			nil,
+		},
+		{
+			"castling rook shields the king destination",
+			"4k3/8/8/8/8/8/8/qRK5 w B - 0 1",
+			nil,
+		},
+		{
+			"king destination is attacked",
+			"4k3/8/8/8/8/8/8/1K3R1r w F - 0 1",
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		var legal MoveList
+		GenLegalMoves(*ParseFen(tc.fen), &legal)
+
+		var got []Move
+		for i := range legal.Len {
+			if legal.Moves[i].Type() == MoveCastling {
+				got = append(got, legal.Moves[i])
+			}
+		}
+
+		if len(got) != len(tc.expected) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.expected, got)
+		}
+		for i := range got {
+			if got[i] != tc.expected[i] {
+				t.Fatalf("%s: expected %v, got %v", tc.name, tc.expected, got)
+			}
+		}
+	}
+}
+
+// TestChess960KingOffFile ensures that the standard castling rights with the
+// king off the e-file enable Chess960, so that the king moves revoke them.
+func TestChess960KingOffFile(t *testing.T) {
+	p, err := ParseFENStrict("r3k2r/8/8/8/8/8/8/R2K3R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Chess960 {
+		t.Fatalf("expected Chess960")
+	}
+
+	for _, uci := range []string{"d1d2", "e8e7", "d2d1", "e7e8"} {
+		m, err := ParseUCIMove(uci, p)
+		if err != nil {
+			t.Fatalf("%s: %v", uci, err)
+		}
+		p.MakeMove(m)
+	}
+
+	var legal MoveList
+	GenLegalMoves(*p, &legal)
+	for i := range legal.Len {
+		if legal.Moves[i].Type() == MoveCastling {
+			t.Fatalf("unexpected castling %s", legal.Moves[i].UCI())
+		}
+	}
+	if p.CastlingRights != 0 {
+		t.Fatalf("expected no castling rights, got %d", p.CastlingRights)
+	}
+}
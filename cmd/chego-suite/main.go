@@ -0,0 +1,245 @@
+// chego-suite runs the engine on the test suites in the EPD format, such as WAC
+// or STS, and reports how many positions are solved.  Use it to measure the
+// strength changes of the engine without an external GUI.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/treepeck/chego"
+	"github.com/treepeck/chego/engine"
+	"github.com/treepeck/chego/epd"
+)
+
+func main() {
+	depth := flag.Int("depth", 0, "Search depth of each position")
+	movetime := flag.Duration("time", 0, "Search time of each position, defaults to 1s without -depth")
+	hash := flag.Int("hash", 16, "Size of the transposition table in megabytes")
+	verbose := flag.Bool("verbose", false, "Whether to print the result of each position")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"Usage: %s [flags] suite.epd...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *depth < 0 || *movetime < 0 || *hash < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	limits := engine.Limits{Depth: *depth, Time: *movetime}
+	if limits.Depth == 0 && limits.Time == 0 {
+		limits.Time = time.Second
+	}
+
+	var total summary
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		fmt.Printf("%s:\n", path)
+		s, err := runSuite(f, engine.New(*hash), limits, *verbose, os.Stdout)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		s.print(os.Stdout)
+		fmt.Println()
+
+		total.add(s)
+	}
+
+	if flag.NArg() > 1 {
+		fmt.Println("Total:")
+		total.print(os.Stdout)
+	}
+}
+
+// summary accumulates the results of the suite.
+type summary struct {
+	positions int
+	solved    int
+	// skipped counts the positions without the "bm" and "am" operations.
+	skipped int
+	// invalid counts the records which cannot be parsed, e.g. because of the
+	// illegal move in "bm".
+	invalid int
+	// solveTime is the sum of the times to solution of the solved positions.
+	solveTime time.Duration
+	nodes     uint64
+	elapsed   time.Duration
+}
+
+func (s *summary) add(other summary) {
+	s.positions += other.positions
+	s.solved += other.solved
+	s.skipped += other.skipped
+	s.invalid += other.invalid
+	s.solveTime += other.solveTime
+	s.nodes += other.nodes
+	s.elapsed += other.elapsed
+}
+
+// print writes the solve rate, the average time to solution, and the speed of
+// the search to w.
+func (s *summary) print(w io.Writer) {
+	rate, avg := 0.0, time.Duration(0)
+	if s.positions > 0 {
+		rate = 100 * float64(s.solved) / float64(s.positions)
+	}
+	if s.solved > 0 {
+		avg = s.solveTime / time.Duration(s.solved)
+	}
+	nps := uint64(0)
+	if s.elapsed > 0 {
+		nps = uint64(float64(s.nodes) / s.elapsed.Seconds())
+	}
+
+	fmt.Fprintf(w, "Solved: %d/%d (%.1f%%)\n", s.solved, s.positions, rate)
+	if s.skipped > 0 {
+		fmt.Fprintf(w, "Skipped: %d positions without bm or am\n", s.skipped)
+	}
+	if s.invalid > 0 {
+		fmt.Fprintf(w, "Invalid: %d records cannot be parsed\n", s.invalid)
+	}
+	fmt.Fprintf(w, "Average time to solution: %s\n", avg.Round(time.Millisecond))
+	fmt.Fprintf(w, "Elapsed time: %s, nodes: %d, nps: %d\n",
+		s.elapsed.Round(time.Millisecond), s.nodes, nps)
+}
+
+// runSuite searches each position of the suite within the limits and writes
+// every failure to w.  If verbose is set, the solved positions are written as
+// well.  The transposition table is cleared before each position.  Records
+// which cannot be parsed are written to w and counted, only the read errors
+// stop the suite.
+func runSuite(r io.Reader, e *engine.Engine, limits engine.Limits, verbose bool,
+	w io.Writer) (summary, error) {
+	var s summary
+	reader := epd.NewReader(r)
+
+	for n := 1; ; n++ {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		if isRecordError(err) {
+			fmt.Fprintln(w, err)
+			s.invalid++
+			continue
+		}
+		if err != nil {
+			return s, err
+		}
+
+		bm, am := rec.Moves("bm"), rec.Moves("am")
+		if bm == nil && am == nil {
+			s.skipped++
+			continue
+		}
+		s.positions++
+
+		res, solveTime, elapsed := search(e, &rec.Position, limits, bm, am)
+		s.nodes += res.Nodes
+		s.elapsed += elapsed
+
+		id := rec.ID()
+		if id == "" {
+			id = fmt.Sprintf("position %d", n)
+		}
+
+		if solveTime >= 0 {
+			s.solved++
+			s.solveTime += solveTime
+			if verbose {
+				fmt.Fprintf(w, "%s: solved %s in %s\n", id, san(rec.Position, res.Move),
+					solveTime.Round(time.Millisecond))
+			}
+			continue
+		}
+
+		expected := ""
+		if bm != nil {
+			expected += " bm " + strings.Join(rec.Operands("bm"), " ")
+		}
+		if am != nil {
+			expected += " am " + strings.Join(rec.Operands("am"), " ")
+		}
+		fmt.Fprintf(w, "%s: failed, expected%s, got %s (score %d, depth %d)\n",
+			id, expected, san(rec.Position, res.Move), res.Score, res.Depth)
+	}
+}
+
+// isRecordError reports whether the error is caused by the invalid record, so
+// the rest of the suite can still be read.
+func isRecordError(err error) bool {
+	var syntaxErr *epd.SyntaxError
+	var moveErr *epd.MoveError
+	var fenErr *chego.FENError
+	return errors.As(err, &syntaxErr) || errors.As(err, &moveErr) ||
+		errors.As(err, &fenErr)
+}
+
+// search runs the engine on the position and returns the result along with the
+// time to solution, i.e. the time of the iteration after which the best move
+// was correct until the end of the search.  The time to solution is negative if
+// the position is not solved.
+func search(e *engine.Engine, p *chego.Position, limits engine.Limits, bm,
+	am []chego.Move) (res engine.Result, solveTime, elapsed time.Duration) {
+	e.Clear()
+
+	solveTime = -1
+	start := time.Now()
+	e.OnIteration = func(r engine.Result) {
+		switch {
+		case !isCorrect(r.Move, bm, am):
+			solveTime = -1
+		case solveTime < 0:
+			solveTime = time.Since(start)
+		}
+	}
+
+	res = e.Search(context.Background(), p, limits)
+	elapsed = time.Since(start)
+	e.OnIteration = nil
+
+	// The interrupted iteration does not change the result.
+	if !isCorrect(res.Move, bm, am) {
+		solveTime = -1
+	}
+	return res, solveTime, elapsed
+}
+
+// isCorrect reports whether the move is one of the best moves and none of the
+// moves to avoid.  Empty bm means that any move which is not avoided is
+// correct.
+func isCorrect(m chego.Move, bm, am []chego.Move) bool {
+	if m == 0 {
+		return false
+	}
+	return (len(bm) == 0 || slices.Contains(bm, m)) && !slices.Contains(am, m)
+}
+
+// san encodes the move in SAN, or returns "none" if there is no move.
+func san(p chego.Position, m chego.Move) string {
+	if m == 0 {
+		return "none"
+	}
+	var l chego.MoveList
+	chego.GenLegalMoves(p, &l)
+	return chego.Move2SAN(m, &p, &l)
+}
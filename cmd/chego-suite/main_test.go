@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/treepeck/chego/engine"
+)
+
+func TestRunSuite(t *testing.T) {
+	suite := `6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8#; id "back rank";
+7k/8/5K2/8/8/8/8/6Q1 w - - am Qg6; id "stalemate trap";
+6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Kf1; id "wrong key";
+4k3/8/8/8/8/8/8/4K3 w - - id "no operations";
+`
+	var out bytes.Buffer
+	s, err := runSuite(strings.NewReader(suite), engine.New(1),
+		engine.Limits{Depth: 3}, true, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.positions != 3 || s.solved != 2 || s.skipped != 1 {
+		t.Fatalf("unexpected summary %+v, output:\n%s", s, out.String())
+	}
+	if !strings.Contains(out.String(), "back rank: solved Ra8#") ||
+		!strings.Contains(out.String(), "wrong key: failed, expected bm Kf1, got Ra8#") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestRunSuiteInvalid(t *testing.T) {
+	suite := `6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra9;
+6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8#; id "back rank";
+8/8/8/8/8/8/8/8 w - - bm e4;
+6k1/5ppp/8/8/8/8/8/R5K1 w - - bm "Ra8#;
+`
+	var out bytes.Buffer
+	s, err := runSuite(strings.NewReader(suite), engine.New(1),
+		engine.Limits{Depth: 1}, false, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The invalid records are reported and the rest of the suite is run.
+	if s.invalid != 3 || s.positions != 1 || s.solved != 1 {
+		t.Fatalf("unexpected summary %+v, output:\n%s", s, out.String())
+	}
+	if !strings.Contains(out.String(), "epd: line 1: bm Ra9: ") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
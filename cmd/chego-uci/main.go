@@ -0,0 +1,317 @@
+// chego-uci is the chess engine which speaks the Universal Chess Interface
+// protocol, so it can be used in chess GUIs and tournament managers.
+//
+// See https://www.wbec-ridderkerk.nl/html/UCIProtocol.html.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/treepeck/chego"
+	"github.com/treepeck/chego/engine"
+)
+
+const (
+	defaultHashMB = 16
+	maxHashMB     = 1024
+	// moveOverhead is reserved for the communication with the GUI to avoid
+	// losing on time.
+	moveOverhead = 50 * time.Millisecond
+)
+
+func main() {
+	newServer(os.Stdout).run(os.Stdin)
+}
+
+// server handles the UCI commands.
+type server struct {
+	// mu protects the output, since the search writes to it concurrently.
+	mu  sync.Mutex
+	out io.Writer
+
+	engine *engine.Engine
+	pos    *chego.Position
+	// history stores the Zobrist keys of the positions played before pos,
+	// so the engine can detect repetitions.
+	history []uint64
+	// chess960 enables the Chess960 castling rules for the next positions.
+	chess960 bool
+
+	// cancel stops the running search.  done is closed once the search
+	// prints the best move.  Both are nil when there is no running search.
+	cancel context.CancelFunc
+	done   chan struct{}
+	// stop is closed by the stop command to release the infinite search.
+	stop chan struct{}
+}
+
+func newServer(out io.Writer) *server {
+	return &server{
+		out:    out,
+		engine: engine.New(defaultHashMB),
+		pos:    chego.ParseFen(chego.InitialPos),
+	}
+}
+
+// run reads and executes the commands until the quit command or the end of the
+// input.
+func (s *server) run(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			s.println("id name chego")
+			s.println("id author treepeck")
+			s.println(fmt.Sprintf("option name Hash type spin default %d min 1 max %d",
+				defaultHashMB, maxHashMB))
+			s.println("option name UCI_Chess960 type check default false")
+			s.println("uciok")
+		case "isready":
+			s.println("readyok")
+		case "ucinewgame":
+			s.stopSearch()
+			s.engine.Clear()
+		case "setoption":
+			s.stopSearch()
+			s.setOption(fields[1:])
+		case "position":
+			s.stopSearch()
+			s.setPosition(fields[1:])
+		case "go":
+			s.stopSearch()
+			s.startSearch(fields[1:])
+		case "stop":
+			s.stopSearch()
+		case "quit":
+			s.stopSearch()
+			return
+		default:
+			s.println("info string unknown command " + fields[0])
+		}
+	}
+	s.stopSearch()
+}
+
+// setOption handles the "setoption name <id> value <x>" command.
+func (s *server) setOption(args []string) {
+	if len(args) != 4 || args[0] != "name" || args[2] != "value" {
+		s.println("info string invalid setoption command")
+		return
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "hash":
+		size, err := strconv.Atoi(args[3])
+		if err != nil || size < 1 || size > maxHashMB {
+			s.println("info string invalid Hash value " + args[3])
+			return
+		}
+		s.engine = engine.New(size)
+	case "uci_chess960":
+		enabled, err := strconv.ParseBool(args[3])
+		if err != nil {
+			s.println("info string invalid UCI_Chess960 value " + args[3])
+			return
+		}
+		s.chess960 = enabled
+	default:
+		s.println("info string unknown option " + args[1])
+	}
+}
+
+// setPosition handles the "position [startpos | fen <fen>] moves <moves>"
+// command.  The current position remains unchanged if the command is invalid.
+func (s *server) setPosition(args []string) {
+	var fen string
+	switch {
+	case len(args) > 0 && args[0] == "startpos":
+		fen, args = chego.InitialPos, args[1:]
+	case len(args) > 6 && args[0] == "fen":
+		fen, args = strings.Join(args[1:7], " "), args[7:]
+	default:
+		s.println("info string invalid position command")
+		return
+	}
+
+	p, err := chego.ParseFENStrict(fen)
+	if err != nil {
+		s.println("info string " + err.Error())
+		return
+	}
+	// Castling moves are sent as the king capturing its own rook in
+	// Chess960, even for the standard rights "KQkq".
+	if s.chess960 {
+		p.Chess960 = true
+	}
+
+	var history []uint64
+	if len(args) > 0 {
+		if args[0] != "moves" {
+			s.println("info string invalid position command")
+			return
+		}
+		for _, str := range args[1:] {
+			m, err := chego.ParseUCIMove(str, p)
+			if err != nil {
+				s.println("info string " + err.Error())
+				return
+			}
+			history = append(history, p.ZobristKey())
+			p.MakeMove(m)
+		}
+	}
+	s.pos, s.history = p, history
+}
+
+// startSearch handles the "go" command and starts the search in the
+// background.
+func (s *server) startSearch(args []string) {
+	limits := engine.Limits{History: s.history}
+	var clock, inc [2]time.Duration
+	movesToGo, infinite := 0, false
+
+	for i := 0; i < len(args); i++ {
+		if args[i] == "infinite" {
+			infinite = true
+			continue
+		}
+		if i+1 == len(args) {
+			break
+		}
+
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			continue
+		}
+		ms := time.Duration(n) * time.Millisecond
+
+		switch args[i] {
+		case "depth":
+			limits.Depth = n
+		case "nodes":
+			limits.Nodes = uint64(n)
+		case "movetime":
+			limits.Time = ms
+		case "wtime":
+			clock[chego.ColorWhite] = ms
+		case "btime":
+			clock[chego.ColorBlack] = ms
+		case "winc":
+			inc[chego.ColorWhite] = ms
+		case "binc":
+			inc[chego.ColorBlack] = ms
+		case "movestogo":
+			movesToGo = n
+		default:
+			continue
+		}
+		i++
+	}
+
+	if !infinite && limits.Time == 0 && clock[s.pos.ActiveColor] > 0 {
+		limits.Time = allocateTime(clock[s.pos.ActiveColor],
+			inc[s.pos.ActiveColor], movesToGo)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done, s.stop = cancel, make(chan struct{}), make(chan struct{})
+
+	start := time.Now()
+	s.engine.OnIteration = func(r engine.Result) {
+		s.println(formatInfo(r, time.Since(start)))
+	}
+
+	// The search works on its own copy, since the position may be changed
+	// by the next command.
+	pos := *s.pos
+	go func(done, stop chan struct{}) {
+		defer close(done)
+		r := s.engine.Search(ctx, &pos, limits)
+		// The infinite search must not print the best move until the stop
+		// command.
+		if infinite {
+			<-stop
+		}
+
+		if r.Move == 0 {
+			s.println("bestmove 0000")
+		} else {
+			s.println("bestmove " + r.Move.UCI())
+		}
+	}(s.done, s.stop)
+}
+
+// stopSearch stops the running search and waits until it prints the best move.
+func (s *server) stopSearch() {
+	if s.done == nil {
+		return
+	}
+	s.cancel()
+	close(s.stop)
+	<-s.done
+	s.cancel, s.done, s.stop = nil, nil, nil
+}
+
+// println writes the line into the output.
+func (s *server) println(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintln(s.out, line)
+}
+
+// allocateTime calculates the time for the current move from the remaining time
+// on the clock, the increment, and the number of moves until the next time
+// control.  If the number of moves is unknown, the game is assumed to last 30
+// more moves.
+func allocateTime(clock, inc time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	t := clock/time.Duration(movesToGo) + inc*3/4
+	// Never use more time than remains on the clock.
+	t = min(t, clock-moveOverhead)
+	return max(t, time.Millisecond)
+}
+
+// formatInfo converts the result of the search iteration into the info line.
+func formatInfo(r engine.Result, elapsed time.Duration) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "info depth %d score ", r.Depth)
+	if r.IsMate() {
+		// UCI measures the distance to the checkmate in moves, not plies.
+		if r.Score > 0 {
+			fmt.Fprintf(&b, "mate %d", (engine.MateScore-r.Score+1)/2)
+		} else {
+			fmt.Fprintf(&b, "mate %d", -(engine.MateScore+r.Score)/2)
+		}
+	} else {
+		fmt.Fprintf(&b, "cp %d", r.Score)
+	}
+
+	ms := elapsed.Milliseconds()
+	fmt.Fprintf(&b, " nodes %d time %d nps %d", r.Nodes, ms,
+		r.Nodes*1000/uint64(max(ms, 1)))
+
+	if len(r.PV) > 0 {
+		b.WriteString(" pv")
+		for _, m := range r.PV {
+			b.WriteString(" " + m.UCI())
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/treepeck/chego"
+	"github.com/treepeck/chego/engine"
+)
+
+func TestServer(t *testing.T) {
+	input := strings.Join([]string{
+		"uci",
+		"setoption name Hash value 1",
+		"isready",
+		"position fen 6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+		"go depth 3",
+		"position startpos moves e2e4 e7e5",
+		"go infinite",
+		"stop",
+		"position fen 7k/5Q2/6K1/8/8/8/8/8 b - - 0 1",
+		"go movetime 10",
+		"quit",
+	}, "\n")
+
+	var out bytes.Buffer
+	newServer(&out).run(strings.NewReader(input))
+
+	var bestMoves []string
+	for line := range strings.Lines(out.String()) {
+		if after, ok := strings.CutPrefix(line, "bestmove "); ok {
+			bestMoves = append(bestMoves, strings.TrimSpace(after))
+		}
+	}
+
+	expected := []string{"a1a8", "b1c3", "0000"}
+	if !strings.Contains(out.String(), "uciok\nreadyok\n") ||
+		strings.Join(bestMoves, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected best moves %v, got output:\n%s", expected, out.String())
+	}
+}
+
+func TestServerInvalidCommands(t *testing.T) {
+	input := strings.Join([]string{
+		"position fen 8/8/8/8/8/8/8/8 w - - 0 1",
+		"position startpos moves e2e5",
+		"setoption name Hash value -1",
+		"foo",
+	}, "\n")
+
+	var out bytes.Buffer
+	s := newServer(&out)
+	s.run(strings.NewReader(input))
+
+	if n := strings.Count(out.String(), "info string"); n != 4 {
+		t.Fatalf("expected 4 error lines, got output:\n%s", out.String())
+	}
+	// Invalid commands must not change the position.
+	if s.pos.ZobristKey() != newServer(&out).pos.ZobristKey() {
+		t.Fatalf("position changed by the invalid command")
+	}
+}
+
+func TestServerHistory(t *testing.T) {
+	// The knight and the queen go back and forth, so g1f3 repeats the
+	// position and draws the lost game.
+	input := strings.Join([]string{
+		"position fen k7/8/8/8/8/8/q7/6NK w - - 10 40 moves g1f3 a2b2 f3g1 b2a2",
+		"go depth 4",
+	}, "\n")
+
+	var out bytes.Buffer
+	s := newServer(&out)
+	s.run(strings.NewReader(input))
+
+	if len(s.history) != 4 || !strings.Contains(out.String(), "score cp 0") ||
+		!strings.Contains(out.String(), "bestmove g1f3\n") {
+		t.Fatalf("expected the repetition, got %d keys and output:\n%s",
+			len(s.history), out.String())
+	}
+}
+
+func TestServerChess960(t *testing.T) {
+	input := strings.Join([]string{
+		"uci",
+		"setoption name UCI_Chess960 value true",
+		"position fen 4k3/8/8/8/8/8/8/4K2R w K - 0 1 moves e1h1",
+	}, "\n")
+
+	var out bytes.Buffer
+	s := newServer(&out)
+	s.run(strings.NewReader(input))
+
+	if !strings.Contains(out.String(), "option name UCI_Chess960 type check") {
+		t.Fatalf("UCI_Chess960 is not announced, got output:\n%s", out.String())
+	}
+	if got := chego.SerializeFen(s.pos); got != "4k3/8/8/8/8/8/8/5RK1 b - - 1 1" {
+		t.Fatalf("unexpected position after castling: %s", got)
+	}
+}
+
+func TestAllocateTime(t *testing.T) {
+	cases := []struct {
+		clock, inc time.Duration
+		movesToGo  int
+		expected   time.Duration
+	}{
+		{time.Minute, 0, 0, 2 * time.Second},
+		{time.Minute, time.Second, 0, 2750 * time.Millisecond},
+		{time.Minute, 0, 10, 6 * time.Second},
+		{40 * time.Millisecond, 0, 1, time.Millisecond},
+	}
+
+	for _, tc := range cases {
+		got := allocateTime(tc.clock, tc.inc, tc.movesToGo)
+		if got != tc.expected {
+			t.Fatalf("expected %s, got %s", tc.expected, got)
+		}
+	}
+}
+
+func TestFormatInfo(t *testing.T) {
+	cases := []struct {
+		score    int
+		expected string
+	}{
+		{35, "info depth 3 score cp 35 nodes 500 time 10 nps 50000"},
+		{engine.MateScore - 3, "info depth 3 score mate 2 nodes 500 time 10 nps 50000"},
+		{-engine.MateScore + 4, "info depth 3 score mate -2 nodes 500 time 10 nps 50000"},
+	}
+
+	for _, tc := range cases {
+		r := engine.Result{Score: tc.score, Depth: 3, Nodes: 500}
+		if got := formatInfo(r, 10*time.Millisecond); got != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
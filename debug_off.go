@@ -0,0 +1,8 @@
+//go:build !chegodebug
+
+package chego
+
+// debug enables the expensive consistency checks, such as the comparison of
+// the incrementally updated Zobrist key with the one computed from scratch.
+// Build with the chegodebug tag to enable them.
+const debug = false
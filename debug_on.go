@@ -0,0 +1,7 @@
+//go:build chegodebug
+
+package chego
+
+// debug enables the expensive consistency checks, such as the comparison of
+// the incrementally updated Zobrist key with the one computed from scratch.
+const debug = true
@@ -0,0 +1,393 @@
+// Package engine implements a simple chess engine on top of the chego move
+// generator: iterative deepening alpha-beta search with quiescence search, the
+// transposition table, and move ordering based on MVV-LVA, killer moves, and
+// the history heuristic.
+//
+// The evaluation only takes into account the material and the piece placement,
+// so the engine is intended for practice bots rather than strong play.
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/treepeck/chego"
+)
+
+const (
+	// MaxPly is the maximum depth of the search, including the quiescence
+	// search.
+	MaxPly = 64
+	// MateScore is the score of the checkmate at the root.  Checkmate in n
+	// plies is scored as MateScore - n, and getting checkmated in n plies as
+	// -MateScore + n.
+	MateScore = 32000
+	// infinity exceeds any possible score.
+	infinity = MateScore + 1
+	// maxHistory limits the history scores to keep them below the killer
+	// moves.
+	maxHistory = orderKiller - 1
+)
+
+// Limits restricts the search.  The search stops as soon as any of the limits
+// is reached.  Zero values mean no limit.  Without any limits the search runs
+// until [MaxPly] depth is reached or the context is canceled.
+type Limits struct {
+	// Depth limits the depth of the main search in plies.
+	Depth int
+	// Nodes limits the number of searched positions.
+	Nodes uint64
+	// Time limits the duration of the search.
+	Time time.Duration
+	// History stores the Zobrist keys of the positions played before the
+	// searched one, oldest first, so that the repetitions of the game history
+	// are scored as draws.  The searched position itself is not included.
+	History []uint64
+}
+
+// Result stores the result of the search.
+type Result struct {
+	// Best move found.  Zero if there are no legal moves.
+	Move chego.Move
+	// Score of the best move in centipawns from the perspective of the active
+	// color.  See [MateScore] for the checkmate scores.
+	Score int
+	// Depth of the last completed iteration.
+	Depth int
+	// Number of searched positions.
+	Nodes uint64
+	// Principal variation: the expected sequence of moves starting from the
+	// best move.
+	PV []chego.Move
+}
+
+// IsMate reports whether the score denotes the checkmate.
+func (r Result) IsMate() bool {
+	return r.Score > MateScore-MaxPly || r.Score < -MateScore+MaxPly
+}
+
+// Engine searches for the best moves.  The transposition table and the history
+// of the move ordering are kept between searches, so the engine should be
+// reused during the game.  Engine is not safe for concurrent use.
+type Engine struct {
+	// OnIteration, if not nil, is called with the intermediate result after
+	// each completed iteration of the search.
+	OnIteration func(Result)
+
+	tt      *table
+	history [64][64]int
+}
+
+// New creates the engine with the transposition table of the specified size in
+// megabytes.
+func New(ttSizeMB int) *Engine {
+	return &Engine{tt: newTable(ttSizeMB)}
+}
+
+// Clear resets the transposition table and the move ordering history.  Should
+// be called before searching positions from an unrelated game.
+func (e *Engine) Clear() {
+	e.tt.clear()
+	e.history = [64][64]int{}
+}
+
+// searcher holds the state of a single search.
+type searcher struct {
+	engine   *Engine
+	ctx      context.Context
+	limits   Limits
+	deadline time.Time
+	nodes    uint64
+	stopped  bool
+	// The first iteration is never interrupted to always have the best move.
+	uninterruptible bool
+	// Zobrist keys of the positions played before the root followed by the
+	// positions on the current search path, used to detect repetitions.
+	keys []uint64
+	// Index of the root position in keys.
+	root int
+	// Quiet moves which caused the beta cutoff at each ply.
+	killers [MaxPly + 1][2]chego.Move
+	// Triangular principal variation table.
+	pv    [MaxPly + 1][MaxPly + 1]chego.Move
+	pvLen [MaxPly + 1]int
+}
+
+// Search searches for the best move in the specified position using iterative
+// deepening.  The search stops when any of the limits is reached or the context
+// is canceled, and the result of the last completed iteration is returned.
+// Since the first iteration is always completed, the returned move is legal
+// unless there are no legal moves at all.
+func (e *Engine) Search(ctx context.Context, p *chego.Position, limits Limits) Result {
+	s := &searcher{engine: e, ctx: ctx, limits: limits}
+	// Positions played before the last 100 halfmoves cannot repeat, since the
+	// game is drawn by the fifty-move rule anyway.
+	history := limits.History[max(len(limits.History)-100, 0):]
+	s.root = len(history)
+	s.keys = append(make([]uint64, 0, s.root+MaxPly+1), history...)
+	s.keys = s.keys[:s.root+MaxPly+1]
+	if limits.Time > 0 {
+		s.deadline = time.Now().Add(limits.Time)
+	}
+
+	// Old history scores are less relevant for the new position.
+	for from := range e.history {
+		for to := range e.history[from] {
+			e.history[from][to] /= 2
+		}
+	}
+
+	maxDepth := MaxPly - 1
+	if limits.Depth > 0 && limits.Depth < maxDepth {
+		maxDepth = limits.Depth
+	}
+
+	pos := *p
+	var result Result
+	for depth := 1; depth <= maxDepth; depth++ {
+		s.uninterruptible = depth == 1
+		// Don't start the next iteration if the search is already stopped.
+		if depth > 1 && (s.ctx.Err() != nil ||
+			!s.deadline.IsZero() && time.Now().After(s.deadline)) {
+			break
+		}
+		score := s.negamax(&pos, depth, -infinity, infinity, 0)
+		if s.stopped {
+			break
+		}
+
+		result = Result{Score: score, Depth: depth, Nodes: s.nodes}
+		if s.pvLen[0] > 0 {
+			result.Move = s.pv[0][0]
+			result.PV = append([]chego.Move(nil), s.pv[0][:s.pvLen[0]]...)
+		}
+		if e.OnIteration != nil {
+			e.OnIteration(result)
+		}
+
+		// There is no need to search deeper once the shortest checkmate
+		// is found.
+		if result.IsMate() && MateScore-abs(score) <= depth {
+			break
+		}
+	}
+
+	result.Nodes = s.nodes
+	return result
+}
+
+// negamax searches the position to the specified depth and returns its score
+// from the perspective of the active color.
+func (s *searcher) negamax(p *chego.Position, depth, alpha, beta, ply int) int {
+	s.pvLen[ply] = 0
+
+	if s.shouldStop() {
+		return 0
+	}
+
+	key := p.ZobristKey()
+	s.keys[s.root+ply] = key
+
+	if ply > 0 && s.isDraw(p, ply) {
+		return 0
+	}
+
+	inCheck := chego.GenChecksCounter(p.Bitboards, 1^p.ActiveColor) > 0
+	// Extend the search in check to find checkmates behind the horizon.
+	if inCheck {
+		depth++
+	}
+
+	if depth <= 0 {
+		return s.quiesce(p, alpha, beta, ply)
+	}
+	s.nodes++
+	if ply >= MaxPly {
+		return Evaluate(p)
+	}
+
+	var ttMove chego.Move
+	if entry, ok := s.engine.tt.probe(key); ok {
+		ttMove = entry.move
+		score := scoreFromTT(int(entry.score), ply)
+		if ply > 0 && int(entry.depth) >= depth {
+			switch {
+			case entry.bound == boundExact,
+				entry.bound == boundLower && score >= beta,
+				entry.bound == boundUpper && score <= alpha:
+				return score
+			}
+		}
+	}
+
+	var legal chego.MoveList
+	chego.GenLegalMoves(*p, &legal)
+	if legal.Len == 0 {
+		if inCheck {
+			return -MateScore + ply
+		}
+		return 0
+	}
+
+	var sm scoredMoves
+	s.scoreMoves(p, &legal, ttMove, ply, &sm)
+
+	best, bestMove, b := -infinity, chego.Move(0), boundUpper
+	for i := range sm.len {
+		m := sm.pick(i)
+		tactical := isTactical(p, m)
+
+		u := p.MakeMove(m)
+		score := -s.negamax(p, depth-1, -beta, -alpha, ply+1)
+		p.UnmakeMove(m, u)
+
+		if s.stopped {
+			return 0
+		}
+
+		if score <= best {
+			continue
+		}
+		best, bestMove = score, m
+		if score <= alpha {
+			continue
+		}
+
+		alpha, b = score, boundExact
+		s.updatePV(m, ply)
+
+		if score >= beta {
+			b = boundLower
+			if !tactical {
+				s.updateQuietStats(m, depth, ply)
+			}
+			break
+		}
+	}
+
+	s.engine.tt.store(key, bestMove, scoreToTT(best, ply), depth, b)
+	return best
+}
+
+// quiesce searches captures and promotions until the position becomes quiet to
+// avoid the horizon effect.  Captures which lose material according to
+// [chego.SEE] are skipped.  In check all evasions are searched.
+func (s *searcher) quiesce(p *chego.Position, alpha, beta, ply int) int {
+	if s.shouldStop() {
+		return 0
+	}
+	s.nodes++
+
+	if ply >= MaxPly {
+		return Evaluate(p)
+	}
+
+	var legal chego.MoveList
+	inCheck := chego.GenChecksCounter(p.Bitboards, 1^p.ActiveColor) > 0
+
+	best := -infinity
+	if inCheck {
+		chego.GenLegalEvasions(*p, &legal)
+		if legal.Len == 0 {
+			return -MateScore + ply
+		}
+	} else {
+		// The side to move may decline all captures.
+		best = Evaluate(p)
+		if best >= beta {
+			return best
+		}
+		alpha = max(alpha, best)
+		chego.GenLegalCaptures(*p, &legal)
+	}
+
+	var sm scoredMoves
+	s.scoreMoves(p, &legal, 0, ply, &sm)
+
+	for i := range sm.len {
+		m := sm.pick(i)
+		if !inCheck && (m.Type() == chego.MovePromotion &&
+			m.PromoPiece() != chego.PromotionQueen || chego.SEE(p, m) < 0) {
+			continue
+		}
+
+		u := p.MakeMove(m)
+		score := -s.quiesce(p, -beta, -alpha, ply+1)
+		p.UnmakeMove(m, u)
+
+		if s.stopped {
+			return 0
+		}
+
+		if score > best {
+			best = score
+			if score >= beta {
+				return score
+			}
+			alpha = max(alpha, score)
+		}
+	}
+
+	return best
+}
+
+// shouldStop checks the limits and the context.  The time and the context are
+// only checked every 1024 nodes, since it's relatively expensive.
+func (s *searcher) shouldStop() bool {
+	if s.stopped || s.uninterruptible {
+		return s.stopped
+	}
+
+	if s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes {
+		s.stopped = true
+	} else if s.nodes&1023 == 0 {
+		s.stopped = s.ctx.Err() != nil ||
+			(!s.deadline.IsZero() && time.Now().After(s.deadline))
+	}
+	return s.stopped
+}
+
+// isDraw reports whether the position is drawn by the fifty-move rule,
+// insufficient material, or repetition of the position on the search path or
+// in the game history.
+func (s *searcher) isDraw(p *chego.Position, ply int) bool {
+	if p.HalfmoveCnt >= 100 || p.IsInsufficientMaterial() {
+		return true
+	}
+
+	// Only positions with the same active color after the last irreversible
+	// move can repeat.
+	cur := s.root + ply
+	for i := cur - 4; i >= 0 && i >= cur-p.HalfmoveCnt; i -= 2 {
+		if s.keys[i] == s.keys[cur] {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePV prepends the move to the principal variation of the next ply.
+func (s *searcher) updatePV(m chego.Move, ply int) {
+	s.pv[ply][0] = m
+	copy(s.pv[ply][1:], s.pv[ply+1][:s.pvLen[ply+1]])
+	s.pvLen[ply] = s.pvLen[ply+1] + 1
+}
+
+// updateQuietStats remembers the quiet move which caused the beta cutoff as the
+// killer move and increases its history score.
+func (s *searcher) updateQuietStats(m chego.Move, depth, ply int) {
+	if s.killers[ply][0] != m {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = m
+	}
+
+	h := &s.engine.history[m.From()][m.To()]
+	*h = min(*h+depth*depth, maxHistory)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/treepeck/chego"
+)
+
+func TestSearch(t *testing.T) {
+	cases := []struct {
+		name     string
+		fen      string
+		expected chego.Move
+		score    int
+	}{
+		{
+			"back rank mate",
+			"6k1/5ppp/8/8/8/8/8/R5K1 w - - 0 1",
+			chego.NewMove(chego.SA8, chego.SA1, chego.MoveNormal), MateScore - 1,
+		},
+		{
+			"stalemate",
+			"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1",
+			0, 0,
+		},
+	}
+
+	for _, tc := range cases {
+		p := chego.ParseFen(tc.fen)
+		got := New(1).Search(context.Background(), p, Limits{Depth: 4})
+
+		if got.Move != tc.expected || got.Score != tc.score {
+			t.Fatalf("case \"%s\" failed: expected %d %d, got %d %d", tc.name,
+				tc.expected, tc.score, got.Move, got.Score)
+		}
+	}
+}
+
+func TestSearchHangingPiece(t *testing.T) {
+	p := chego.ParseFen("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	got := New(1).Search(context.Background(), p, Limits{Depth: 3})
+
+	expected := chego.NewMove(chego.SD5, chego.SD2, chego.MoveNormal)
+	if got.Move != expected {
+		t.Fatalf("expected %d, got %d", expected, got.Move)
+	}
+	if got.IsMate() || got.Score < 300 {
+		t.Fatalf("expected winning score, got %d", got.Score)
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	p := chego.ParseFen("k7/8/8/8/8/8/q7/6NK w - - 10 40")
+	nf3 := chego.NewMove(chego.SF3, chego.SG1, chego.MoveNormal)
+
+	// The knight and the queen go back and forth, so Nf3 repeats the position
+	// played before the root.
+	var history []uint64
+	pos := *p
+	for _, m := range []chego.Move{
+		nf3,
+		chego.NewMove(chego.SB2, chego.SA2, chego.MoveNormal),
+		chego.NewMove(chego.SG1, chego.SF3, chego.MoveNormal),
+		chego.NewMove(chego.SA2, chego.SB2, chego.MoveNormal),
+	} {
+		history = append(history, pos.ZobristKey())
+		pos.MakeMove(m)
+	}
+
+	got := New(1).Search(context.Background(), &pos, Limits{Depth: 4})
+	if got.Score >= 0 {
+		t.Fatalf("expected losing score without history, got %d", got.Score)
+	}
+
+	got = New(1).Search(context.Background(), &pos,
+		Limits{Depth: 4, History: history})
+	if got.Move != nf3 || got.Score != 0 {
+		t.Fatalf("expected %d 0, got %d %d", nf3, got.Move, got.Score)
+	}
+}
+
+func TestSearchPV(t *testing.T) {
+	p := chego.ParseFen(chego.InitialPos)
+	got := New(1).Search(context.Background(), p, Limits{Depth: 5})
+
+	if got.Depth != 5 {
+		t.Fatalf("expected depth 5, got %d", got.Depth)
+	}
+	if len(got.PV) == 0 || got.PV[0] != got.Move {
+		t.Fatalf("PV %v does not begin with the best move %d", got.PV, got.Move)
+	}
+
+	// Each move of the PV must be legal.
+	for _, m := range got.PV {
+		var l chego.MoveList
+		chego.GenLegalMoves(*p, &l)
+
+		legal := false
+		for i := range l.Len {
+			legal = legal || l.Moves[i] == m
+		}
+		if !legal {
+			t.Fatalf("PV move %d is illegal in %s", m, chego.SerializeFen(p))
+		}
+		p.MakeMove(m)
+	}
+}
+
+func TestSearchLimits(t *testing.T) {
+	p := chego.ParseFen(chego.InitialPos)
+	e := New(1)
+
+	got := e.Search(context.Background(), p, Limits{Nodes: 5000})
+	// The node limit is checked before each node is searched.
+	if got.Nodes > 5000 || got.Move == 0 {
+		t.Fatalf("node limit: got %d nodes, move %d", got.Nodes, got.Move)
+	}
+
+	start := time.Now()
+	got = e.Search(context.Background(), p, Limits{Time: 50 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > time.Second || got.Move == 0 {
+		t.Fatalf("time limit: took %s, move %d", elapsed, got.Move)
+	}
+
+	// The first iteration is completed even if the context is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got = e.Search(ctx, p, Limits{})
+	if got.Depth != 1 || got.Move == 0 {
+		t.Fatalf("canceled context: got depth %d, move %d", got.Depth, got.Move)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p := chego.ParseFen(chego.InitialPos)
+	if got := Evaluate(p); got != 0 {
+		t.Fatalf("expected 0 in the initial position, got %d", got)
+	}
+
+	// The evaluation is symmetric for both colors.
+	p = chego.ParseFen("4k3/8/8/3q4/8/8/3R4/4K3 w - - 0 1")
+	white := Evaluate(p)
+	p = chego.ParseFen("4k3/3r4/8/8/3Q4/8/8/4K3 b - - 0 1")
+	if black := Evaluate(p); white != black {
+		t.Fatalf("expected %d, got %d", white, black)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	p := chego.ParseFen(chego.InitialPos)
+	e := New(16)
+
+	for b.Loop() {
+		e.Clear()
+		e.Search(context.Background(), p, Limits{Depth: 5})
+	}
+}
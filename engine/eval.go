@@ -0,0 +1,115 @@
+// eval.go implements the static evaluation of positions.
+
+package engine
+
+import (
+	"math/bits"
+
+	"github.com/treepeck/chego"
+)
+
+// Values of the pieces in centipawns.  Use Piece type as index to get it's
+// value.  Kings are never captured, so their values are zero.
+var pieceValues = [12]int{100, 100, 320, 320, 330, 330, 500, 500, 900, 900, 0, 0}
+
+// Piece-square tables define the bonus of each piece for standing on the square.
+// Tables are written from White's perspective with the eighth rank first, so
+// they look like the chessboard.
+//
+// See https://www.chessprogramming.org/Simplified_Evaluation_Function.
+var pieceSquareTables = [6][64]int{
+	// Pawns.
+	{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+	// Knights.
+	{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	},
+	// Bishops.
+	{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	},
+	// Rooks.
+	{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	},
+	// Queens.
+	{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	},
+	// Kings.  Encourages castling and keeping the king behind the pawns.
+	{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	},
+}
+
+// Evaluate statically evaluates the position in centipawns from the perspective
+// of the active color.  Only the material and the piece placement are taken into
+// account.
+func Evaluate(p *chego.Position) int {
+	score := 0
+
+	for piece := chego.WPawn; piece <= chego.BKing; piece++ {
+		bitboard := p.Bitboards[piece]
+		table := &pieceSquareTables[piece/2]
+
+		for bitboard > 0 {
+			square := bits.TrailingZeros64(bitboard)
+			bitboard &= bitboard - 1
+			if piece%2 == chego.ColorWhite {
+				// Flip the rank, since tables begin with the eighth rank.
+				score += pieceValues[piece] + table[square^56]
+			} else {
+				score -= pieceValues[piece] + table[square]
+			}
+		}
+	}
+
+	if p.ActiveColor == chego.ColorBlack {
+		return -score
+	}
+	return score
+}
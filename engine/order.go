@@ -0,0 +1,93 @@
+// order.go implements move ordering.  Searching the best moves first produces
+// more cutoffs in the alpha-beta search.
+
+package engine
+
+import "github.com/treepeck/chego"
+
+// Scores of the move categories.  Moves are searched in the following order:
+// the transposition table move, captures and promotions, killer moves, and
+// quiet moves sorted by their history.
+const (
+	orderTTMove  = 1 << 30
+	orderCapture = 1 << 20
+	orderKiller  = 1 << 19
+)
+
+// scoredMoves stores the moves along with their ordering scores.
+type scoredMoves struct {
+	moves  [218]chego.Move
+	scores [218]int
+	len    int
+}
+
+// scoreMoves assigns the ordering score to each move of the list.
+func (s *searcher) scoreMoves(p *chego.Position, l *chego.MoveList,
+	ttMove chego.Move, ply int, sm *scoredMoves) {
+	sm.len = int(l.Len)
+
+	for i := range sm.len {
+		m := l.Moves[i]
+		sm.moves[i] = m
+
+		switch {
+		case m == ttMove:
+			sm.scores[i] = orderTTMove
+		case isTactical(p, m):
+			sm.scores[i] = orderCapture + mvvLVA(p, m)
+		case m == s.killers[ply][0]:
+			sm.scores[i] = orderKiller + 1
+		case m == s.killers[ply][1]:
+			sm.scores[i] = orderKiller
+		default:
+			sm.scores[i] = s.engine.history[m.From()][m.To()]
+		}
+	}
+}
+
+// pick returns the move with the highest score among the moves starting from
+// the i-th one and swaps it with the i-th move.  The selection sort is used,
+// since the cutoff usually happens after a few moves.
+func (sm *scoredMoves) pick(i int) chego.Move {
+	best := i
+	for j := i + 1; j < sm.len; j++ {
+		if sm.scores[j] > sm.scores[best] {
+			best = j
+		}
+	}
+
+	sm.moves[i], sm.moves[best] = sm.moves[best], sm.moves[i]
+	sm.scores[i], sm.scores[best] = sm.scores[best], sm.scores[i]
+	return sm.moves[i]
+}
+
+// isTactical reports whether the move is a capture or a promotion.
+func isTactical(p *chego.Position, m chego.Move) bool {
+	switch m.Type() {
+	case chego.MovePromotion, chego.MoveEnPassant:
+		return true
+	case chego.MoveCastling:
+		return false
+	}
+	return p.Bitboards[12+(1^p.ActiveColor)]&(1<<m.To()) != 0
+}
+
+// mvvLVA scores the capture by the Most Valuable Victim - Least Valuable
+// Aggressor heuristic.  Promotions are scored by the value of the promoted
+// piece.
+func mvvLVA(p *chego.Position, m chego.Move) int {
+	score := 0
+	if m.Type() == chego.MovePromotion {
+		score += pieceValues[chego.WKnight+2*m.PromoPiece()]
+	}
+
+	victim := chego.WPawn
+	if m.Type() != chego.MoveEnPassant {
+		victim = p.GetPieceFromSquare(1 << m.To())
+	}
+	if victim != chego.PieceNone {
+		aggressor := p.GetPieceFromSquare(1 << m.From())
+		score += 10*pieceValues[victim] - pieceValues[aggressor]
+	}
+	return score
+}
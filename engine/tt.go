@@ -0,0 +1,90 @@
+// tt.go implements the transposition table.
+
+package engine
+
+import "github.com/treepeck/chego"
+
+// bound defines how the stored score relates to the exact score of the
+// position.
+type bound uint8
+
+const (
+	// The score is exact.
+	boundExact bound = iota
+	// The score is a lower bound, since the search failed high.
+	boundLower
+	// The score is an upper bound, since the search failed low.
+	boundUpper
+)
+
+// ttEntry stores the search result of a single position.
+type ttEntry struct {
+	key   uint64
+	move  chego.Move
+	score int16
+	depth int8
+	bound bound
+}
+
+// ttEntrySize is the size of a single entry in bytes, including padding.
+const ttEntrySize = 16
+
+// table is the transposition table which stores the search results of
+// positions, indexed by their Zobrist keys.  Entries are always replaced, since
+// the newer results are usually more relevant.
+type table struct {
+	entries []ttEntry
+	mask    uint64
+}
+
+// newTable creates the transposition table which occupies at most the
+// specified number of megabytes.  The number of entries is rounded down to the
+// power of two.
+func newTable(sizeMB int) *table {
+	n := uint64(1)
+	for n*2*ttEntrySize <= uint64(max(sizeMB, 1))<<20 {
+		n *= 2
+	}
+	return &table{entries: make([]ttEntry, n), mask: n - 1}
+}
+
+// probe returns the entry of the position with the specified key.
+func (t *table) probe(key uint64) (ttEntry, bool) {
+	e := t.entries[key&t.mask]
+	return e, e.key == key
+}
+
+// store saves the search result of the position with the specified key.
+// score must be already adjusted with [scoreToTT].
+func (t *table) store(key uint64, m chego.Move, score, depth int, b bound) {
+	t.entries[key&t.mask] = ttEntry{
+		key: key, move: m, score: int16(score), depth: int8(depth), bound: b,
+	}
+}
+
+// clear removes all entries from the table.
+func (t *table) clear() {
+	clear(t.entries)
+}
+
+// scoreToTT converts the mate score relative to the root into the score
+// relative to the position at the specified ply, so that it stays valid when
+// the position is reached through a different path.
+func scoreToTT(score, ply int) int {
+	if score > MateScore-MaxPly {
+		return score + ply
+	} else if score < -MateScore+MaxPly {
+		return score - ply
+	}
+	return score
+}
+
+// scoreFromTT reverts [scoreToTT].
+func scoreFromTT(score, ply int) int {
+	if score > MateScore-MaxPly {
+		return score - ply
+	} else if score < -MateScore+MaxPly {
+		return score + ply
+	}
+	return score
+}
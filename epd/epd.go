@@ -0,0 +1,199 @@
+// Package epd implements parsing and serialization of chess positions stored in
+// the Extended Position Description, which is used by the test suites.
+//
+// See http://www.saremba.de/chessgml/standards/pgn/pgn-complete.htm Section 16.2.
+package epd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+var (
+	// ErrMissingOperation is returned when the record has no operation with
+	// the requested opcode.
+	ErrMissingOperation = errors.New("missing operation")
+	// ErrInvalidOperand is returned when the operand cannot be converted into
+	// the requested type.
+	ErrInvalidOperand = errors.New("invalid operand")
+)
+
+// Opcodes which operands are moves in SAN.  The moves of "pv" are played one
+// after another, while the rest are the alternatives in the record position.
+var moveOpcodes = map[string]bool{
+	"am": true, "bm": true, "pm": true, "sm": true, "pv": true,
+}
+
+// Operation is a single EPD operation, e.g. bm Nf3 e4;
+type Operation struct {
+	Opcode string
+	// Operands are stored without the quotes of the string operands.
+	Operands []string
+	// Moves stores the operands of the move opcodes (am, bm, pm, sm and pv)
+	// parsed from SAN.  nil for other opcodes.
+	Moves []chego.Move
+}
+
+// Record represents a single EPD line.
+type Record struct {
+	// Position is described by the first four fields.  The halfmove and
+	// fullmove counters are taken from the "hmvc" and "fmvn" operations.
+	Position chego.Position
+	// Operations are stored in the order in which they have been read or set.
+	Operations []Operation
+}
+
+// NewRecord creates a new record of the specified position without operations.
+func NewRecord(p chego.Position) *Record {
+	return &Record{Position: p}
+}
+
+// Operation returns the operation with the specified opcode, or nil if the
+// record does not have it.
+func (r *Record) Operation(opcode string) *Operation {
+	for i := range r.Operations {
+		if r.Operations[i].Opcode == opcode {
+			return &r.Operations[i]
+		}
+	}
+	return nil
+}
+
+// Operands returns the operands of the operation with the specified opcode, or
+// nil if the record does not have it.
+func (r *Record) Operands(opcode string) []string {
+	if op := r.Operation(opcode); op != nil {
+		return op.Operands
+	}
+	return nil
+}
+
+// Moves returns the moves of the operation with the specified opcode, e.g.
+// "bm", or nil if the record does not have it.
+func (r *Record) Moves(opcode string) []chego.Move {
+	if op := r.Operation(opcode); op != nil {
+		return op.Moves
+	}
+	return nil
+}
+
+// SetOperands replaces the operands of the operation with the specified opcode,
+// or appends the new operation if the record does not have it.  Use
+// [Record.SetMoves] for the move opcodes.
+func (r *Record) SetOperands(opcode string, operands ...string) {
+	r.set(Operation{Opcode: opcode, Operands: operands})
+}
+
+// SetMoves replaces the moves of the operation with the specified opcode, or
+// appends the new operation if the record does not have it.  The operands are
+// encoded in SAN.  Returns an error wrapping [chego.ErrIllegalMove] if one of
+// the moves is not legal.
+func (r *Record) SetMoves(opcode string, moves ...chego.Move) error {
+	op := Operation{Opcode: opcode, Moves: moves}
+
+	p := r.Position
+	var l chego.MoveList
+	chego.GenLegalMoves(p, &l)
+
+	for _, m := range moves {
+		if !contains(&l, m) {
+			return fmt.Errorf("%w: %s", chego.ErrIllegalMove, m.UCI())
+		}
+
+		if opcode == "pv" {
+			op.Operands = append(op.Operands, chego.Move2SAN(m, &p, &l))
+			continue
+		}
+		// Each move is played from the record position.
+		pos, legal := p, l
+		op.Operands = append(op.Operands, chego.Move2SAN(m, &pos, &legal))
+	}
+
+	r.set(op)
+	return nil
+}
+
+// ID returns the first operand of the "id" operation, or an empty string if the
+// record does not have it.
+func (r *Record) ID() string {
+	if ops := r.Operands("id"); len(ops) > 0 {
+		return ops[0]
+	}
+	return ""
+}
+
+// Comment returns the first operand of the comment operation "c0" to "c9" with
+// the specified number, or an empty string if the record does not have it.
+func (r *Record) Comment(n int) string {
+	if ops := r.Operands("c" + strconv.Itoa(n)); len(ops) > 0 {
+		return ops[0]
+	}
+	return ""
+}
+
+// Int returns the first operand of the operation with the specified opcode as
+// an integer, e.g. for "acd" (analysis count depth) or "ce" (centipawn
+// evaluation).
+func (r *Record) Int(opcode string) (int, error) {
+	ops := r.Operands(opcode)
+	if len(ops) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrMissingOperation, opcode)
+	}
+
+	n, err := strconv.Atoi(ops[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s %q", ErrInvalidOperand, opcode, ops[0])
+	}
+	return n, nil
+}
+
+// Perft returns the expected node counts of the perft suite, stored in the
+// "D1" to "Dn" operations, indexed by depth.
+func (r *Record) Perft() (map[int]uint64, error) {
+	nodes := make(map[int]uint64)
+
+	for _, op := range r.Operations {
+		if len(op.Opcode) < 2 || op.Opcode[0] != 'D' {
+			continue
+		}
+		depth, err := strconv.Atoi(op.Opcode[1:])
+		if err != nil || depth < 1 {
+			continue
+		}
+
+		if len(op.Operands) != 1 {
+			return nil, fmt.Errorf("%w: %s %s", ErrInvalidOperand, op.Opcode,
+				strings.Join(op.Operands, " "))
+		}
+		cnt, err := strconv.ParseUint(op.Operands[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s %q", ErrInvalidOperand, op.Opcode,
+				op.Operands[0])
+		}
+		nodes[depth] = cnt
+	}
+	return nodes, nil
+}
+
+// contains reports whether the move list contains the specified move.
+func contains(l *chego.MoveList, m chego.Move) bool {
+	for i := range l.Len {
+		if l.Moves[i] == m {
+			return true
+		}
+	}
+	return false
+}
+
+// set replaces the operation with the same opcode or appends the new one.
+func (r *Record) set(op Operation) {
+	if old := r.Operation(op.Opcode); old != nil {
+		*old = op
+		return
+	}
+	r.Operations = append(r.Operations, op)
+}
@@ -0,0 +1,272 @@
+// reader.go implements parsing of EPD records.
+
+package epd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+// SyntaxError is returned when the EPD line cannot be parsed.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("epd: line %d: %s", e.Line, e.Msg)
+}
+
+// MoveError is returned when the move operand cannot be parsed from SAN.
+type MoveError struct {
+	Line   int
+	Opcode string
+	SAN    string
+	Err    error
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("epd: line %d: %s %s: %v", e.Line, e.Opcode, e.SAN, e.Err)
+}
+
+func (e *MoveError) Unwrap() error { return e.Err }
+
+// Reader reads consecutive records from the EPD file line by line, so large
+// files are never loaded into memory at once.
+type Reader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+// NewReader creates a new reader which reads records from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{s: bufio.NewScanner(r)}
+}
+
+// Line returns the number of the line from which the last record has been
+// read, counting from one.
+func (r *Reader) Line() int {
+	return r.line
+}
+
+// Parse parses a single record from the specified line.
+func Parse(line string) (*Record, error) {
+	return parseRecord(line, 1)
+}
+
+// Read reads the next record.  Empty lines and lines starting with '#' are
+// skipped.  Returns io.EOF when there are no more records.
+func (r *Reader) Read() (*Record, error) {
+	for r.s.Scan() {
+		r.line++
+
+		line := strings.TrimSpace(r.s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		return parseRecord(line, r.line)
+	}
+
+	if err := r.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// parseRecord parses the record from the line with the specified number.
+//
+// The four position fields may be followed by the halfmove and fullmove
+// counters, as in FEN, which are often found in the perft suites.  The
+// terminating semicolon of the last operation may be omitted.
+func parseRecord(line string, n int) (*Record, error) {
+	sc := scanner{s: line}
+
+	var fields [4]string
+	for i := range fields {
+		if fields[i] = sc.token(); fields[i] == "" {
+			return nil, &SyntaxError{Line: n, Msg: "missing position field"}
+		}
+	}
+
+	// Optional FEN counters.
+	start := sc.i
+	halfmove, fullmove := sc.token(), sc.token()
+	if !isNumber(halfmove) || !isNumber(fullmove) {
+		halfmove, fullmove = "0", "1"
+		sc.i = start
+	}
+
+	r := &Record{}
+	for {
+		op, err := sc.operation()
+		if err != nil {
+			return nil, &SyntaxError{Line: n, Msg: err.Error()}
+		}
+		if op.Opcode == "" {
+			break
+		}
+		r.Operations = append(r.Operations, op)
+	}
+
+	if ops := r.Operands("hmvc"); len(ops) > 0 {
+		halfmove = ops[0]
+	}
+	if ops := r.Operands("fmvn"); len(ops) > 0 {
+		fullmove = ops[0]
+	}
+
+	p, err := chego.ParseFENStrict(strings.Join(fields[:], " ") + " " +
+		halfmove + " " + fullmove)
+	if err != nil {
+		return nil, fmt.Errorf("epd: line %d: %w", n, err)
+	}
+	r.Position = *p
+
+	for i := range r.Operations {
+		op := &r.Operations[i]
+		if !moveOpcodes[op.Opcode] {
+			continue
+		}
+		if op.Moves, err = parseMoves(op, *p); err != nil {
+			return nil, &MoveError{Line: n, Opcode: op.Opcode,
+				SAN: op.Operands[len(op.Moves)], Err: err}
+		}
+	}
+
+	return r, nil
+}
+
+// parseMoves parses the move operands of the operation in the specified
+// position.  On error, returns the moves parsed before the invalid operand.
+func parseMoves(op *Operation, p chego.Position) ([]chego.Move, error) {
+	moves := make([]chego.Move, 0, len(op.Operands))
+
+	for _, san := range op.Operands {
+		m, err := chego.ParseSAN(san, &p)
+		if err != nil {
+			return moves, err
+		}
+		moves = append(moves, m)
+
+		// The principal variation is played one move after another.
+		if op.Opcode == "pv" {
+			p.MakeMove(m)
+		}
+	}
+	return moves, nil
+}
+
+// isNumber reports whether s is a non-negative decimal number.
+func isNumber(s string) bool {
+	_, err := strconv.ParseUint(s, 10, 32)
+	return err == nil
+}
+
+// scanner splits the EPD line into tokens.
+type scanner struct {
+	s string
+	i int
+}
+
+// skipSpaces advances the scanner to the next non-space character.
+func (sc *scanner) skipSpaces() {
+	for sc.i < len(sc.s) && (sc.s[sc.i] == ' ' || sc.s[sc.i] == '\t') {
+		sc.i++
+	}
+}
+
+// token reads the next token until the space or semicolon.  Returns an empty
+// string at the end of the operation.
+func (sc *scanner) token() string {
+	sc.skipSpaces()
+	start := sc.i
+	for sc.i < len(sc.s) && sc.s[sc.i] != ' ' && sc.s[sc.i] != '\t' &&
+		sc.s[sc.i] != ';' {
+		sc.i++
+	}
+	return sc.s[start:sc.i]
+}
+
+// operation reads the next operation.  Empty operations, i.e. redundant
+// semicolons, are skipped.  Returns the operation with an empty opcode at the
+// end of the line.
+func (sc *scanner) operation() (Operation, error) {
+	var op Operation
+
+	for op.Opcode == "" {
+		sc.skipSpaces()
+		if sc.i == len(sc.s) {
+			return op, nil
+		}
+		if sc.s[sc.i] == ';' {
+			sc.i++
+			continue
+		}
+		op.Opcode = sc.token()
+		if !isOpcode(op.Opcode) {
+			return op, fmt.Errorf("invalid opcode %q", op.Opcode)
+		}
+	}
+
+	for {
+		sc.skipSpaces()
+		switch {
+		case sc.i == len(sc.s):
+			return op, nil
+		case sc.s[sc.i] == ';':
+			sc.i++
+			return op, nil
+		case sc.s[sc.i] == '"':
+			s, err := sc.quoted()
+			if err != nil {
+				return op, err
+			}
+			op.Operands = append(op.Operands, s)
+		default:
+			op.Operands = append(op.Operands, sc.token())
+		}
+	}
+}
+
+// quoted reads the string operand enclosed in double quotes.  Backslash escapes
+// the quote and the backslash itself.
+func (sc *scanner) quoted() (string, error) {
+	var b strings.Builder
+
+	for sc.i++; sc.i < len(sc.s); sc.i++ {
+		c := sc.s[sc.i]
+		switch {
+		case c == '"':
+			sc.i++
+			return b.String(), nil
+		case c == '\\' && sc.i+1 < len(sc.s):
+			sc.i++
+			b.WriteByte(sc.s[sc.i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated string")
+}
+
+// isOpcode reports whether s is a valid opcode: a letter followed by up to 14
+// letters, digits and underscores.
+func isOpcode(s string) bool {
+	if len(s) == 0 || len(s) > 15 || !isLetter(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isLetter(s[i]) && !(s[i] >= '0' && s[i] <= '9') && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
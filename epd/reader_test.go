@@ -0,0 +1,146 @@
+package epd
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "mate; in 3"; acd 12; ce 32767; pv Qg6 fxg6 Nxg6+;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.ID() != "WAC.001" || r.Comment(0) != "mate; in 3" || r.Comment(1) != "" {
+		t.Fatalf("unexpected operations: %+v", r.Operations)
+	}
+	if acd, err := r.Int("acd"); err != nil || acd != 12 {
+		t.Fatalf("expected acd 12, got %d %v", acd, err)
+	}
+	if _, err := r.Int("dm"); !errors.Is(err, ErrMissingOperation) {
+		t.Fatalf("expected ErrMissingOperation, got %v", err)
+	}
+	if _, err := r.Int("id"); !errors.Is(err, ErrInvalidOperand) {
+		t.Fatalf("expected ErrInvalidOperand, got %v", err)
+	}
+
+	bm := r.Moves("bm")
+	if len(bm) != 1 || bm[0] != chego.NewMove(chego.SG6, chego.SG3, chego.MoveNormal) {
+		t.Fatalf("unexpected best moves: %v", bm)
+	}
+	// The second and the third moves of the variation are only legal after
+	// the previous ones.
+	pv := r.Moves("pv")
+	if len(pv) != 3 || pv[2] != chego.NewMove(chego.SG6, chego.SE5, chego.MoveNormal) {
+		t.Fatalf("unexpected principal variation: %v", pv)
+	}
+	if r.Position.HalfmoveCnt != 0 || r.Position.FullmoveCnt != 1 {
+		t.Fatalf("unexpected counters: %d %d", r.Position.HalfmoveCnt,
+			r.Position.FullmoveCnt)
+	}
+}
+
+func TestParseCounters(t *testing.T) {
+	cases := []struct {
+		line               string
+		halfmove, fullmove int
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 5 40 ;D1 5 ;D2 25", 5, 40},
+		{"4k3/8/8/8/8/8/8/4K3 w - - hmvc 7; fmvn 12;", 7, 12},
+		{"4k3/8/8/8/8/8/8/4K3 w - -", 0, 1},
+	}
+
+	for _, tc := range cases {
+		r, err := Parse(tc.line)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.line, err)
+		}
+		if r.Position.HalfmoveCnt != tc.halfmove || r.Position.FullmoveCnt != tc.fullmove {
+			t.Fatalf("%s: unexpected counters %d %d", tc.line,
+				r.Position.HalfmoveCnt, r.Position.FullmoveCnt)
+		}
+	}
+}
+
+func TestPerft(t *testing.T) {
+	r, err := Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - ;D1 20 ;D2 400 ;D3 8902")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := r.Perft()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 3 || nodes[1] != 20 || nodes[3] != 8902 {
+		t.Fatalf("unexpected node counts: %v", nodes)
+	}
+
+	r.SetOperands("D4", "many")
+	if _, err = r.Perft(); !errors.Is(err, ErrInvalidOperand) {
+		t.Fatalf("expected ErrInvalidOperand, got %v", err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var syntaxErr *SyntaxError
+	var moveErr *MoveError
+	var fenErr *chego.FENError
+
+	cases := []struct {
+		line   string
+		target any
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w -", &syntaxErr},
+		{`4k3/8/8/8/8/8/8/4K3 w - - id "unterminated;`, &syntaxErr},
+		{"4k3/8/8/8/8/8/8/4K3 w - - 1bm Kd2;", &syntaxErr},
+		{"4k3/8/8/8/8/8/8/4K4 w - - bm Kd2;", &fenErr},
+		{"4k3/8/8/8/8/8/8/4K3 w - - bm Kd2 Ke3;", &moveErr},
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(tc.line)
+		if !errors.As(err, tc.target) {
+			t.Fatalf("%s: unexpected error %v", tc.line, err)
+		}
+	}
+
+	if moveErr.SAN != "Ke3" || !errors.Is(moveErr, chego.ErrIllegalMove) {
+		t.Fatalf("unexpected move error: %v", moveErr)
+	}
+}
+
+func TestReader(t *testing.T) {
+	input := `# Comment line.
+4k3/8/8/8/8/8/8/4K3 w - - id "first";
+
+4k3/8/8/8/8/8/8/4K3 b - - id "second";
+4k3/8/8/8/8/8/8/4K3 w - - bm Kd9;
+`
+	r := NewReader(strings.NewReader(input))
+
+	for _, id := range []string{"first", "second"} {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.ID() != id {
+			t.Fatalf("expected id %s, got %s", id, rec.ID())
+		}
+	}
+	if r.Line() != 4 {
+		t.Fatalf("expected line 4, got %d", r.Line())
+	}
+
+	var moveErr *MoveError
+	if _, err := r.Read(); !errors.As(err, &moveErr) || moveErr.Line != 5 {
+		t.Fatalf("expected move error on line 5, got %v", err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// writer.go implements serialization of EPD records.
+
+package epd
+
+import (
+	"io"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+// Write writes the record into w as a single EPD line.
+func Write(w io.Writer, r *Record) error {
+	_, err := io.WriteString(w, r.String()+"\n")
+	return err
+}
+
+// String returns the record as an EPD line without the line terminator.
+//
+// The position is followed by the operations in their order, each terminated
+// by a semicolon.  The counters of the position are not written, use the
+// "hmvc" and "fmvn" operations to store them.
+func (r *Record) String() string {
+	var b strings.Builder
+
+	// EPD shares the first four fields with FEN.
+	fields := strings.SplitN(chego.SerializeFen(&r.Position), " ", 5)
+	b.WriteString(strings.Join(fields[:4], " "))
+
+	for _, op := range r.Operations {
+		b.WriteByte(' ')
+		b.WriteString(op.Opcode)
+		for _, operand := range op.Operands {
+			b.WriteByte(' ')
+			writeOperand(&b, op.Opcode, operand)
+		}
+		b.WriteByte(';')
+	}
+
+	return b.String()
+}
+
+// writeOperand writes the operand, enclosing it in double quotes if it is the
+// operand of the string opcode or cannot be read back otherwise.
+func writeOperand(b *strings.Builder, opcode, operand string) {
+	if !isStringOpcode(opcode) && operand != "" &&
+		!strings.ContainsAny(operand, " \t;\"") {
+		b.WriteString(operand)
+		return
+	}
+
+	b.WriteByte('"')
+	for i := range len(operand) {
+		if operand[i] == '"' || operand[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(operand[i])
+	}
+	b.WriteByte('"')
+}
+
+// isStringOpcode reports whether the operands of the opcode are strings: the
+// identifier and the comments "c0" to "c9".
+func isStringOpcode(opcode string) bool {
+	return opcode == "id" ||
+		len(opcode) == 2 && opcode[0] == 'c' && opcode[1] >= '0' && opcode[1] <= '9'
+}
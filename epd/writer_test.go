@@ -0,0 +1,55 @@
+package epd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestString(t *testing.T) {
+	cases := []string{
+		`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "quote \" and \\ backslash";`,
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 am e5 d5; acd 20; ce -15; pv c5 Nf3;",
+		`4k3/8/8/8/8/8/8/4K3 w - - hmvc 3; fmvn 50; c1 "";`,
+	}
+
+	for _, line := range cases {
+		r, err := Parse(line)
+		if err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		if got := r.String(); got != line {
+			t.Fatalf("expected %s\ngot %s", line, got)
+		}
+	}
+}
+
+func TestSetMoves(t *testing.T) {
+	r := NewRecord(*chego.ParseFen("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1"))
+	r.SetOperands("id", "castling")
+
+	castle := chego.NewMove(chego.SG1, chego.SE1, chego.MoveCastling)
+	if err := r.SetMoves("bm", castle, chego.NewMove(chego.SA8, chego.SA1,
+		chego.MoveNormal)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMoves("pv", castle, chego.NewMove(chego.SC8, chego.SE8,
+		chego.MoveCastling)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMoves("am", chego.NewMove(chego.SE3, chego.SE1,
+		chego.MoveNormal)); !errors.Is(err, chego.ErrIllegalMove) {
+		t.Fatalf("expected ErrIllegalMove, got %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := Write(&b, r); err != nil {
+		t.Fatal(err)
+	}
+	expected := `r3k2r/8/8/8/8/8/8/R3K2R w KQkq - id "castling"; bm O-O Rxa8+; pv O-O O-O-O;` + "\n"
+	if b.String() != expected {
+		t.Fatalf("expected %s got %s", expected, b.String())
+	}
+}
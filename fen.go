@@ -5,6 +5,38 @@ import (
 	"strings"
 )
 
+// FENField is an allias type to avoid bothersome conversion between int and
+// FENField.
+type FENField = int
+
+// Fields of the FEN string in the order they appear.
+const (
+	FENFieldPlacement FENField = iota
+	FENFieldActiveColor
+	FENFieldCastling
+	FENFieldEnPassant
+	FENFieldHalfmove
+	FENFieldFullmove
+)
+
+// fenFieldNames maps each FEN field to its name used in error messages.
+var fenFieldNames = [6]string{
+	"piece placement", "active color", "castling rights",
+	"en passant target", "halfmove clock", "fullmove number",
+}
+
+// FENError is returned when the FEN string cannot be parsed by [ParseFENStrict].
+type FENError struct {
+	// Field which failed the validation.
+	Field FENField
+	// Reason describes why the field is invalid.
+	Reason string
+}
+
+func (e *FENError) Error() string {
+	return "invalid FEN " + fenFieldNames[e.Field] + ": " + e.Reason
+}
+
 // FEN of the standard initial chess position.
 const InitialPos = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
@@ -28,7 +60,8 @@ var (
 )
 
 // ParseFen parses the given FEN string into a [Position].
-// It's the caller's responsibility to validate fen.
+// It's the caller's responsibility to validate fen.  Use [ParseFENStrict] to
+// parse FEN strings from untrusted sources.
 //
 // fen must have six parts, separated by a space:
 //  1. Piece placement: will be parsed into the array of bitboards.
@@ -36,7 +69,9 @@ var (
 //     "w" means that White is to move;
 //     "b" means that Black is to move.
 //  3. Castling rights: if neither side has the ability to castle,
-//     this field uses the character "-".
+//     this field uses the character "-".  Shredder-FEN and X-FEN rook files
+//     are supported as well, see [Position.Chess960].  The rights without
+//     the matching king and rook are ignored.
 //  4. En passant target square: if there is no en passant target square,
 //     this field uses the character "-".
 //  5. Halfmove clock: used for the fifty-move rule.
@@ -57,18 +92,22 @@ func ParseFen(fen string) *Position {
 	}
 
 	// Parse castling rights.
+	var rooks [4]int
 	for i := range len(fields[2]) {
-		switch fields[2][i] {
-		case 'K':
-			p.CastlingRights |= CastlingWhiteShort
-		case 'Q':
-			p.CastlingRights |= CastlingWhiteLong
-		case 'k':
-			p.CastlingRights |= CastlingBlackShort
-		case 'q':
-			p.CastlingRights |= CastlingBlackLong
+		c := fields[2][i]
+		index, rook := p.parseCastlingRight(c)
+		if index == -1 {
+			// Skip the rights without the matching king and rook.
+			continue
+		}
+		p.CastlingRights |= 1 << index
+		rooks[index] = rook
+		// Rook files are only used in Chess960.
+		if strings.IndexByte("KQkq", c) == -1 {
+			p.Chess960 = true
 		}
 	}
+	p.setCastlingRooks(rooks)
 
 	// Parse en passant target square.
 	for i := range Square2String {
@@ -90,11 +129,263 @@ func ParseFen(fen string) *Position {
 		panic("cannot parse fullmove counter from FEN string")
 	}
 
+	p.Refresh()
+
 	return &p
 }
 
+// ParseFENStrict validates the given FEN string and parses it into a [Position].
+// Unlike [ParseFen], it never panics and returns a [*FENError] which describes
+// the first invalid field.
+//
+// Besides the syntax of each field, the following is validated:
+//   - Each side has exactly one king;
+//   - There are no pawns on the first and eighth ranks;
+//   - Castling rights match the placement of kings and rooks.  Both standard
+//     and Chess960 rights are accepted, see [ParseFen];
+//   - The en passant target square is behind the pawn which has just made a
+//     double push;
+//   - The side which is not to move is not in check.
+func ParseFENStrict(fen string) (*Position, error) {
+	fields := strings.Split(fen, " ")
+	if len(fields) < 6 {
+		return nil, &FENError{Field: len(fields), Reason: "missing field"}
+	}
+	if len(fields) > 6 {
+		return nil, &FENError{Field: FENFieldFullmove, Reason: "unexpected data " +
+			strconv.Quote(strings.Join(fields[6:], " "))}
+	}
+
+	var p Position
+	if err := validatePlacement(fields[0]); err != nil {
+		return nil, err
+	}
+	p.Bitboards = ParseBitboards(fields[0])
+
+	// Validate kings and pawns.
+	if CountBits(p.Bitboards[WKing]) != 1 || CountBits(p.Bitboards[BKing]) != 1 {
+		return nil, &FENError{Field: FENFieldPlacement,
+			Reason: "each side must have exactly one king"}
+	}
+	if (p.Bitboards[WPawn]|p.Bitboards[BPawn])&(rank1|rank8) != 0 {
+		return nil, &FENError{Field: FENFieldPlacement,
+			Reason: "pawns on the first or eighth rank"}
+	}
+
+	switch fields[1] {
+	case "w":
+		p.ActiveColor = ColorWhite
+	case "b":
+		p.ActiveColor = ColorBlack
+	default:
+		return nil, &FENError{Field: FENFieldActiveColor,
+			Reason: "expected \"w\" or \"b\", got " + strconv.Quote(fields[1])}
+	}
+	if GenChecksCounter(p.Bitboards, p.ActiveColor) > 0 {
+		return nil, &FENError{Field: FENFieldActiveColor,
+			Reason: "the side not to move is in check"}
+	}
+
+	if err := p.parseCastlingStrict(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if err := p.parseEPTargetStrict(fields[3]); err != nil {
+		return nil, err
+	}
+
+	var err error
+	p.HalfmoveCnt, err = strconv.Atoi(fields[4])
+	if err != nil || p.HalfmoveCnt < 0 {
+		return nil, &FENError{Field: FENFieldHalfmove,
+			Reason: "expected non-negative number, got " + strconv.Quote(fields[4])}
+	}
+
+	p.FullmoveCnt, err = strconv.Atoi(fields[5])
+	if err != nil || p.FullmoveCnt < 1 {
+		return nil, &FENError{Field: FENFieldFullmove,
+			Reason: "expected positive number, got " + strconv.Quote(fields[5])}
+	}
+
+	p.Refresh()
+
+	return &p, nil
+}
+
+// validatePlacement ensures that the piece placement consists of eight ranks
+// with eight files each, and contains only valid characters.
+func validatePlacement(placement string) error {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return &FENError{Field: FENFieldPlacement,
+			Reason: "expected 8 ranks, got " + strconv.Itoa(len(ranks))}
+	}
+
+	for i, rank := range ranks {
+		files := 0
+		for j := range len(rank) {
+			c := rank[j]
+			if c >= '1' && c <= '8' {
+				files += int(c - '0')
+			} else if strings.IndexByte(string(PieceSymbols[:]), c) != -1 {
+				files++
+			} else {
+				return &FENError{Field: FENFieldPlacement,
+					Reason: "unknown character " + strconv.QuoteRune(rune(c))}
+			}
+		}
+
+		if files != 8 {
+			return &FENError{Field: FENFieldPlacement, Reason: "rank " +
+				strconv.Itoa(8-i) + " has " + strconv.Itoa(files) + " files"}
+		}
+	}
+	return nil
+}
+
+// parseCastlingStrict parses the castling rights and ensures that each right has
+// a king and a rook on the first rank of its color.
+func (p *Position) parseCastlingStrict(castling string) error {
+	if castling == "-" {
+		return nil
+	}
+
+	var rooks [4]int
+	for i := range len(castling) {
+		c := strconv.QuoteRune(rune(castling[i]))
+
+		if strings.IndexByte("KQkqABCDEFGHabcdefgh", castling[i]) == -1 {
+			return &FENError{Field: FENFieldCastling,
+				Reason: "unknown character " + c}
+		}
+
+		index, rook := p.parseCastlingRight(castling[i])
+		if index == -1 {
+			return &FENError{Field: FENFieldCastling,
+				Reason: "no king or rook on the first rank for " + c}
+		}
+
+		right := CastlingRights(1 << index)
+		if p.CastlingRights&right != 0 {
+			return &FENError{Field: FENFieldCastling,
+				Reason: "duplicate right " + c}
+		}
+		p.CastlingRights |= right
+		rooks[index] = rook
+
+		if strings.IndexByte("KQkq", castling[i]) == -1 {
+			p.Chess960 = true
+		}
+	}
+	p.setCastlingRooks(rooks)
+	return nil
+}
+
+// parseCastlingRight returns the index of the castling right in the order of
+// [CastlingRights] bits and the initial square of its rook.  c is either one of
+// "KQkq", which denote the outermost rook on the corresponding side of the king,
+// or the rook file as in Shredder-FEN and X-FEN, e.g. 'H' or 'a'.  Returns -1
+// index if there is no such rook or the king is not on the first rank of its
+// color.
+func (p *Position) parseCastlingRight(c byte) (index, rook int) {
+	color, first := ColorWhite, 0
+	if c >= 'a' {
+		color, first = ColorBlack, 56
+		c -= 'a' - 'A'
+	}
+
+	kingBB := p.Bitboards[WKing+color]
+	king := bitScan(kingBB)
+	if kingBB == 0 || king/8 != first/8 {
+		return -1, 0
+	}
+	rooks := p.Bitboards[WRook+color]
+
+	switch {
+	case c == 'K':
+		for sq := first + 7; sq > king; sq-- {
+			if rooks&(1<<sq) != 0 {
+				return 2 * color, sq
+			}
+		}
+	case c == 'Q':
+		for sq := first; sq < king; sq++ {
+			if rooks&(1<<sq) != 0 {
+				return 2*color + 1, sq
+			}
+		}
+	case c >= 'A' && c <= 'H':
+		sq := first + int(c-'A')
+		if rooks&(1<<sq) != 0 && sq > king {
+			return 2 * color, sq
+		} else if rooks&(1<<sq) != 0 && sq < king {
+			return 2*color + 1, sq
+		}
+	}
+	return -1, 0
+}
+
+// setCastlingRooks stores the initial rook squares of the castling rights and
+// enables Chess960 if any of them is not on the corner or the king is not on
+// the e-file, since the standard castling rules would not apply.
+func (p *Position) setCastlingRooks(rooks [4]int) {
+	for i := range rooks {
+		if p.CastlingRights&(1<<i) == 0 {
+			continue
+		}
+		king := bitScan(p.Bitboards[WKing+i/2])
+		if rooks[i] != standardCastlingRooks[i] || king != SE1+56*(i/2) {
+			p.Chess960 = true
+		}
+		p.CastlingRooks[i] = rooks[i]
+	}
+}
+
+// parseEPTargetStrict parses the en passant target square and ensures that the
+// pawn of the inactive color has just made a double push over it.  The active
+// color and bitboards must be already parsed.
+func (p *Position) parseEPTargetStrict(ep string) error {
+	if ep == "-" {
+		return nil
+	}
+
+	square := -1
+	for i := range Square2String {
+		if Square2String[i] == ep {
+			square = i
+		}
+	}
+	if square == -1 {
+		return &FENError{Field: FENFieldEnPassant, Reason: "invalid square " +
+			strconv.Quote(ep)}
+	}
+
+	// Rank of the target square, the square of the pushed pawn, and the
+	// initial square of the pushed pawn.
+	rank, pawn, initial := 5, square-8, square+8
+	if p.ActiveColor == ColorBlack {
+		rank, pawn, initial = 2, square+8, square-8
+	}
+
+	if square/8 != rank {
+		return &FENError{Field: FENFieldEnPassant, Reason: "square " + ep +
+			" is on the wrong rank"}
+	}
+	if p.Bitboards[WPawn+(1^p.ActiveColor)]&(1<<pawn) == 0 ||
+		p.Bitboards[14]&(1<<square|1<<initial) != 0 {
+		return &FENError{Field: FENFieldEnPassant, Reason: "no pawn has just " +
+			"made a double push over " + ep}
+	}
+
+	p.EPTarget = square
+	return nil
+}
+
 // SerializeFen serializes the specified [Position] into a FEN string.
 // It's the caller's responsibility to validate p.
+//
+// Castling rights of Chess960 positions are written in Shredder-FEN, e.g.
+// "HAha", so that [ParseFen] enables [Position.Chess960] back.
 func SerializeFen(p *Position) string {
 	var fen strings.Builder
 	fen.Grow(64)
@@ -111,20 +402,16 @@ func SerializeFen(p *Position) string {
 
 	// 3 field: castling rights.
 	cnt := 4
-	if p.CastlingRights&CastlingWhiteShort != 0 {
-		fen.WriteByte('K')
-		cnt--
-	}
-	if p.CastlingRights&CastlingWhiteLong != 0 {
-		fen.WriteByte('Q')
-		cnt--
-	}
-	if p.CastlingRights&CastlingBlackShort != 0 {
-		fen.WriteByte('k')
-		cnt--
-	}
-	if p.CastlingRights&CastlingBlackLong != 0 {
-		fen.WriteByte('q')
+	for i := range 4 {
+		if p.CastlingRights&(1<<i) == 0 {
+			continue
+		}
+		if p.Chess960 {
+			// Black rights are written in lowercase.
+			fen.WriteByte("ABCDEFGHabcdefgh"[p.CastlingRooks[i]%8+8*(i/2)])
+		} else {
+			fen.WriteByte("KQkq"[i])
+		}
 		cnt--
 	}
 	if cnt == 4 {
@@ -1,6 +1,7 @@
 package chego
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -88,6 +89,7 @@ func TestParseFEN(t *testing.T) {
 				EPTarget:       SA1,
 				HalfmoveCnt:    0,
 				FullmoveCnt:    1,
+				CastlingRooks:  [4]int{SH1, SA1, SH8, SA8},
 			},
 		},
 		{
@@ -98,6 +100,18 @@ func TestParseFEN(t *testing.T) {
 				EPTarget:       SE3,
 				HalfmoveCnt:    0,
 				FullmoveCnt:    1,
+				CastlingRooks:  [4]int{SH1, SA1, SH8, SA8},
+			},
+		},
+		{
+			"1nbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			Position{
+				ActiveColor:    ColorWhite,
+				CastlingRights: CastlingWhiteShort | CastlingWhiteLong | CastlingBlackShort,
+				EPTarget:       SA1,
+				HalfmoveCnt:    0,
+				FullmoveCnt:    1,
+				CastlingRooks:  [4]int{SH1, SA1, SH8, 0},
 			},
 		},
 	}
@@ -105,11 +119,70 @@ func TestParseFEN(t *testing.T) {
 	for _, tc := range cases {
 		p := ParseFen(tc.fen)
 		tc.expected.Bitboards = p.Bitboards
+		tc.expected.Refresh()
 
 		if *p != tc.expected {
 			t.Fatalf("expected %v\ngot %v", tc.expected, p)
 		}
 	}
+
+	// The rights without the matching rook are dropped.
+	fen := "1nbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+	if got := SerializeFen(ParseFen(fen)); got != "1nbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQk - 0 1" {
+		t.Fatalf("expected the black long castling right to be dropped, got %s", got)
+	}
+}
+
+func TestParseFENStrict(t *testing.T) {
+	cases := []struct {
+		fen   string
+		field FENField
+	}{
+		{InitialPos, -1},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", -1},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Kq - 10 40", -1},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", FENFieldHalfmove},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 x", FENFieldFullmove},
+		{"rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/9/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/7/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/ppppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1", FENFieldPlacement},
+		{"rnbq1bnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKKNR w kq - 0 1", FENFieldPlacement},
+		{"rnbqkbnP/pppppppp/8/8/8/8/PPPPPPP1/RNBQKBNR w KQkq - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", FENFieldActiveColor},
+		{"4k3/8/8/8/8/8/8/4K2r b - - 0 1", FENFieldActiveColor},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1", FENFieldCastling},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KKkq - 0 1", FENFieldCastling},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w KQkq - 0 1", FENFieldCastling},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w - - 0 1", FENFieldPlacement},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e4 0 1", FENFieldEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq d3 0 1", FENFieldEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e3 0 1", FENFieldEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq z3 0 1", FENFieldEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", FENFieldHalfmove},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", FENFieldFullmove},
+	}
+
+	for _, tc := range cases {
+		p, err := ParseFENStrict(tc.fen)
+
+		if tc.field == -1 {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", tc.fen, err)
+			}
+			if got := SerializeFen(p); got != tc.fen {
+				t.Fatalf("expected %s, got %s", tc.fen, got)
+			}
+			continue
+		}
+
+		var fenErr *FENError
+		if !errors.As(err, &fenErr) || fenErr.Field != tc.field {
+			t.Fatalf("%s: expected error in field %d, got %v", tc.fen, tc.field, err)
+		}
+	}
 }
 
 // TestSerializeFEN does not check the serialized bitboards, since that is the job
@@ -167,6 +240,12 @@ func BenchmarkParseFEN(b *testing.B) {
 	}
 }
 
+func BenchmarkParseFENStrict(b *testing.B) {
+	for b.Loop() {
+		ParseFENStrict("rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1")
+	}
+}
+
 func BenchmarkSerializeFEN(b *testing.B) {
 	p := &Position{
 		Bitboards: [15]uint64{
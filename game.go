@@ -0,0 +1,216 @@
+// game.go implements chess game management: move history, undo, and detection
+// of the game result.
+
+package chego
+
+// Result is an allias type to avoid bothersome conversion between int and Result.
+type Result = int
+
+const (
+	// The game is still in progress.
+	ResultUnknown Result = iota
+	ResultWhiteWon
+	ResultBlackWon
+	ResultDraw
+)
+
+// Termination is an allias type to avoid bothersome conversion between int and
+// Termination.
+type Termination = int
+
+const (
+	// The game is still in progress.
+	TerminationNone Termination = iota
+	TerminationCheckmate
+	TerminationStalemate
+	TerminationInsufficientMaterial
+	// 50 moves by each player without any capture or pawn move.
+	TerminationFiftyMoves
+	// The same position has occured three times.  The draw can be claimed.
+	TerminationThreefoldRepetition
+	// The same position has occured five times.  The game is drawn
+	// automatically.
+	TerminationFivefoldRepetition
+)
+
+// PlayedMove stores the move played in the game along with its SAN.
+type PlayedMove struct {
+	Move Move
+	SAN  string
+}
+
+// Game represents a chess game: the starting position and the sequence of played
+// moves.  Legal moves of the current position are cached and updated after each
+// move.
+type Game struct {
+	// FEN of the starting position.
+	StartFEN string
+	// Current position.  Must not be modified directly, use [Game.Push] and
+	// [Game.Pop] instead.
+	Position Position
+	// Legal moves for the current position.
+	LegalMoves MoveList
+	// Played moves in the order they were played.
+	Moves []PlayedMove
+	// Positions before each played move.  Used to undo moves.
+	history []Position
+	// Zobrist keys of all positions which have occured in the game, including
+	// the current one.  See [Game.positionKey].
+	keys []uint64
+}
+
+// NewGame creates a new game which starts from the specified position.  It's
+// the caller's responsibility to validate fen.
+func NewGame(fen string) *Game {
+	g := &Game{StartFEN: fen, Position: *ParseFen(fen)}
+	GenLegalMoves(g.Position, &g.LegalMoves)
+	g.keys = append(g.keys, g.positionKey())
+	return g
+}
+
+// IsLegal reports whether the specified move is legal in the current position.
+func (g *Game) IsLegal(m Move) bool {
+	for i := range g.LegalMoves.Len {
+		if g.LegalMoves.Moves[i] == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Push plays the specified move.  Returns [ErrIllegalMove] if the move is not
+// legal in the current position.
+func (g *Game) Push(m Move) error {
+	if !g.IsLegal(m) {
+		return ErrIllegalMove
+	}
+
+	g.history = append(g.history, g.Position)
+	// Move2SAN also updates the position and legal moves.
+	san := Move2SAN(m, &g.Position, &g.LegalMoves)
+	g.Moves = append(g.Moves, PlayedMove{Move: m, SAN: san})
+	g.keys = append(g.keys, g.positionKey())
+	return nil
+}
+
+// PushSAN parses the SAN string and plays the resulting move.
+func (g *Game) PushSAN(san string) error {
+	m, err := ParseSAN(san, &g.Position)
+	if err != nil {
+		return err
+	}
+	return g.Push(m)
+}
+
+// Pop takes back the last played move and returns it.  Returns false if there
+// are no moves to take back.
+func (g *Game) Pop() (PlayedMove, bool) {
+	n := len(g.Moves)
+	if n == 0 {
+		return PlayedMove{}, false
+	}
+
+	last := g.Moves[n-1]
+	g.Position = g.history[n-1]
+	g.Moves = g.Moves[:n-1]
+	g.history = g.history[:n-1]
+	g.keys = g.keys[:n]
+	GenLegalMoves(g.Position, &g.LegalMoves)
+	return last, true
+}
+
+// IsCheck reports whether the king of the active color is under attack.
+func (g *Game) IsCheck() bool {
+	return GenChecksCounter(g.Position.Bitboards, 1^g.Position.ActiveColor) > 0
+}
+
+// Termination returns the reason why the game has ended, or [TerminationNone]
+// if the game is still in progress.
+func (g *Game) Termination() Termination {
+	switch {
+	case g.LegalMoves.Len == 0 && g.IsCheck():
+		return TerminationCheckmate
+	case g.LegalMoves.Len == 0:
+		return TerminationStalemate
+	case g.Position.IsInsufficientMaterial():
+		return TerminationInsufficientMaterial
+	case g.Position.HalfmoveCnt >= 100:
+		return TerminationFiftyMoves
+	case g.IsFivefoldRepetition():
+		return TerminationFivefoldRepetition
+	case g.IsThreefoldRepetition():
+		return TerminationThreefoldRepetition
+	}
+	return TerminationNone
+}
+
+// Result returns the result of the game, or [ResultUnknown] if the game is still
+// in progress.
+func (g *Game) Result() Result {
+	switch g.Termination() {
+	case TerminationNone:
+		return ResultUnknown
+	case TerminationCheckmate:
+		if g.Position.ActiveColor == ColorWhite {
+			return ResultBlackWon
+		}
+		return ResultWhiteWon
+	}
+	return ResultDraw
+}
+
+// IsThreefoldRepetition reports whether the current position has occured at
+// least three times.  In that case the draw can be claimed by the player.
+func (g *Game) IsThreefoldRepetition() bool {
+	return g.repetitions() >= 3
+}
+
+// IsFivefoldRepetition reports whether the current position has occured at
+// least five times.  In that case the game is drawn automatically.
+func (g *Game) IsFivefoldRepetition() bool {
+	return g.repetitions() >= 5
+}
+
+// repetitions returns the number of times the current position has occured in
+// the game.
+//
+// Only the positions since the last irreversible move are compared, since the
+// positions before it cannot occur again.  The move is irreversible if it is a
+// pawn move, a capture, or it changes the castling rights.
+func (g *Game) repetitions() int {
+	current := g.keys[len(g.keys)-1]
+	cnt := 1
+
+	pos := g.Position
+	for i := len(g.history) - 1; i >= 0; i-- {
+		prev := g.history[i]
+		// pos is reached from prev by playing the i-th move.
+		if pos.HalfmoveCnt == 0 || pos.CastlingRights != prev.CastlingRights {
+			break
+		}
+		if g.keys[i] == current {
+			cnt++
+		}
+		pos = prev
+	}
+	return cnt
+}
+
+// positionKey returns the Zobrist key of the current position which is used to
+// detect repetitions.
+//
+// According to the FIDE Laws of Chess (Article 9.2.3) positions are the same if
+// the same player has the move, pieces occupy the same squares, and the possible
+// moves of all the pieces are the same.  Hence the en passant target is taken
+// into account only if the en passant capture is legal.  The legal moves must
+// be generated before calling this function.
+func (g *Game) positionKey() uint64 {
+	key := g.Position.ZobristKey()
+	for i := range g.LegalMoves.Len {
+		if g.LegalMoves.Moves[i].Type() == MoveEnPassant {
+			return key
+		}
+	}
+	// Exclude the en passant target from the key.
+	return key ^ g.Position.epKey()
+}
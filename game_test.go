@@ -0,0 +1,187 @@
+package chego
+
+import "testing"
+
+func TestGameTermination(t *testing.T) {
+	cases := []struct {
+		name        string
+		fen         string
+		moves       []string
+		termination Termination
+		result      Result
+	}{
+		{
+			"scholar's mate",
+			InitialPos,
+			[]string{"e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6", "Qxf7#"},
+			TerminationCheckmate, ResultWhiteWon,
+		},
+		{
+			"fool's mate",
+			InitialPos,
+			[]string{"f3", "e5", "g4", "Qh4#"},
+			TerminationCheckmate, ResultBlackWon,
+		},
+		{
+			"stalemate",
+			"7k/8/6K1/8/8/8/8/5Q2 w - - 0 1",
+			[]string{"Qf7"},
+			TerminationStalemate, ResultDraw,
+		},
+		{
+			"insufficient material",
+			"7k/8/6K1/8/8/8/8/6nR w - - 0 1",
+			[]string{"Rxg1"},
+			TerminationNone, ResultUnknown,
+		},
+		{
+			"insufficient material after capture",
+			"7k/8/6K1/8/8/7n/8/6R1 b - - 0 1",
+			[]string{"Nxg1"},
+			TerminationInsufficientMaterial, ResultDraw,
+		},
+		{
+			"fifty moves",
+			"7k/8/6K1/8/8/8/8/6R1 w - - 99 80",
+			[]string{"Rg2"},
+			TerminationFiftyMoves, ResultDraw,
+		},
+		{
+			"threefold repetition",
+			InitialPos,
+			[]string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8"},
+			TerminationThreefoldRepetition, ResultDraw,
+		},
+		{
+			"twofold repetition",
+			InitialPos,
+			[]string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1"},
+			TerminationNone, ResultUnknown,
+		},
+	}
+
+	for _, tc := range cases {
+		g := NewGame(tc.fen)
+		for _, san := range tc.moves {
+			if err := g.PushSAN(san); err != nil {
+				t.Fatalf("%s: %s: %v", tc.name, san, err)
+			}
+		}
+
+		if got := g.Termination(); got != tc.termination {
+			t.Fatalf("%s: expected termination %d, got %d", tc.name, tc.termination, got)
+		}
+		if got := g.Result(); got != tc.result {
+			t.Fatalf("%s: expected result %d, got %d", tc.name, tc.result, got)
+		}
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	shuffle := []string{"Nf3", "Nf6", "Ng1", "Ng8"}
+
+	cases := []struct {
+		name      string
+		fen       string
+		moves     []string
+		threefold bool
+		fivefold  bool
+	}{
+		{
+			"fivefold",
+			InitialPos,
+			append(append(append(shuffle, shuffle...), shuffle...), shuffle...),
+			true, true,
+		},
+		{
+			"pawn move resets the window",
+			InitialPos,
+			append(append([]string{"Nf3", "Nf6", "Ng1", "Ng8", "e3", "e6"},
+				shuffle...), "Nf3", "Nf6", "Ng1"),
+			false, false,
+		},
+		{
+			"castling rights change resets the window",
+			"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			[]string{"Ke2", "Ke7", "Ke1", "Ke8", "Ke2", "Ke7", "Ke1", "Ke8"},
+			false, false,
+		},
+		{
+			"castling rights remain the same",
+			"r3k2r/8/8/8/8/8/8/R3K2R w - - 0 1",
+			[]string{"Ke2", "Ke7", "Ke1", "Ke8", "Ke2", "Ke7", "Ke1", "Ke8"},
+			true, false,
+		},
+		{
+			// The first position is different since the en passant capture
+			// is possible.
+			"en passant rights",
+			"4k3/8/8/8/3p4/8/4P3/4K3 w - - 0 1",
+			[]string{"e4", "Ke7", "Ke2", "Ke8", "Ke1", "Ke7", "Ke2", "Ke8", "Ke1"},
+			false, false,
+		},
+		{
+			// The en passant target is set, but the capture is not possible.
+			"no en passant rights",
+			"4k3/8/8/8/8/3p4/4P3/4K3 w - - 0 1",
+			[]string{"e4", "Ke7", "Kf2", "Ke8", "Ke1", "Ke7", "Kf2", "Ke8", "Ke1"},
+			true, false,
+		},
+	}
+
+	for _, tc := range cases {
+		g := NewGame(tc.fen)
+		for _, san := range tc.moves {
+			if err := g.PushSAN(san); err != nil {
+				t.Fatalf("%s: %s: %v", tc.name, san, err)
+			}
+		}
+
+		if got := g.IsThreefoldRepetition(); got != tc.threefold {
+			t.Fatalf("%s: expected threefold %t, got %t", tc.name, tc.threefold, got)
+		}
+		if got := g.IsFivefoldRepetition(); got != tc.fivefold {
+			t.Fatalf("%s: expected fivefold %t, got %t", tc.name, tc.fivefold, got)
+		}
+	}
+}
+
+func TestGamePushPop(t *testing.T) {
+	g := NewGame(InitialPos)
+
+	if err := g.Push(NewMove(SE5, SE2, MoveNormal)); err != ErrIllegalMove {
+		t.Fatalf("expected ErrIllegalMove, got %v", err)
+	}
+
+	for _, san := range []string{"e4", "d5", "exd5", "Qxd5"} {
+		if err := g.PushSAN(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Moves[2].SAN != "exd5" || g.LegalMoves.Len == 0 {
+		t.Fatalf("unexpected moves: %v", g.Moves)
+	}
+
+	for range 4 {
+		if _, ok := g.Pop(); !ok {
+			t.Fatal("expected move to be popped")
+		}
+	}
+	if _, ok := g.Pop(); ok {
+		t.Fatal("expected no moves to pop")
+	}
+
+	if got := SerializeFen(&g.Position); got != InitialPos {
+		t.Fatalf("expected %s, got %s", InitialPos, got)
+	}
+	if g.LegalMoves.Len != 20 || len(g.keys) != 1 {
+		t.Fatalf("unexpected game state after undo")
+	}
+}
+
+func BenchmarkGamePush(b *testing.B) {
+	for b.Loop() {
+		g := NewGame(InitialPos)
+		g.Push(NewMove(SE4, SE2, MoveNormal))
+	}
+}
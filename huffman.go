@@ -0,0 +1,388 @@
+// huffman.go implements compact binary encoding of games.  Each move is encoded
+// as the Huffman code of its index in the list of legal moves.  Popular moves
+// tend to have small indices, so they get the shortest codes.
+//
+// The codes are generated by the internal/codegen tool.
+
+package chego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidEncoding is returned when the encoded game is malformed.
+var ErrInvalidEncoding = errors.New("invalid game encoding")
+
+// maxForcedMoves is the number of forced moves allowed on top of one move per
+// encoded bit.  Forced moves take zero bits, so without the limit a crafted move
+// count would make [DecodeGame] loop for an arbitrarily long time.
+const maxForcedMoves = 1024
+
+// huffmanCode represents the Huffman code of a single legal move index.  Codes
+// are written starting from the most significant bit.
+type huffmanCode struct {
+	code uint32
+	len  int
+}
+
+// huffmanCodes maps the index of the legal move to its Huffman code.  The codes
+// were generated from 9974 Lichess games with 855264 moves in total.  The number
+// of times each index was played is kept in the comments, so the table can be
+// rebuilt by the internal/codegen test without the original database.
+var huffmanCodes = [218]huffmanCode{
+	{0b0111, 4},                   // index 0 | played 51814 times
+	{0b1110, 4},                   // index 1 | played 39687 times
+	{0b1100, 4},                   // index 2 | played 42641 times
+	{0b1111, 4},                   // index 3 | played 39130 times
+	{0b00101, 5},                  // index 4 | played 34532 times
+	{0b00001, 5},                  // index 5 | played 35859 times
+	{0b00011, 5},                  // index 6 | played 35097 times
+	{0b1101, 4},                   // index 7 | played 40702 times
+	{0b00111, 5},                  // index 8 | played 33132 times
+	{0b00010, 5},                  // index 9 | played 35151 times
+	{0b01001, 5},                  // index 10 | played 27938 times
+	{0b01101, 5},                  // index 11 | played 26870 times
+	{0b01100, 5},                  // index 12 | played 26875 times
+	{0b10001, 5},                  // index 13 | played 25866 times
+	{0b10000, 5},                  // index 14 | played 25931 times
+	{0b10011, 5},                  // index 15 | played 24380 times
+	{0b10101, 5},                  // index 16 | played 24135 times
+	{0b10010, 5},                  // index 17 | played 24889 times
+	{0b01010, 5},                  // index 18 | played 27217 times
+	{0b10110, 5},                  // index 19 | played 23007 times
+	{0b000001, 6},                 // index 20 | played 18812 times
+	{0b001000, 6},                 // index 21 | played 17620 times
+	{0b001001, 6},                 // index 22 | played 17163 times
+	{0b001101, 6},                 // index 23 | played 16910 times
+	{0b010001, 6},                 // index 24 | played 14882 times
+	{0b010110, 6},                 // index 25 | played 13972 times
+	{0b101000, 6},                 // index 26 | played 12470 times
+	{0b101110, 6},                 // index 27 | played 11562 times
+	{0b101111, 6},                 // index 28 | played 10429 times
+	{0b0000001, 7},                // index 29 | played 9422 times
+	{0b0011001, 7},                // index 30 | played 8417 times
+	{0b0100000, 7},                // index 31 | played 7784 times
+	{0b0101110, 7},                // index 32 | played 6867 times
+	{0b1010010, 7},                // index 33 | played 6137 times
+	{0b1010011, 7},                // index 34 | played 5623 times
+	{0b00000000, 8},               // index 35 | played 5082 times
+	{0b00110000, 8},               // index 36 | played 4423 times
+	{0b01000010, 8},               // index 37 | played 3867 times
+	{0b01000011, 8},               // index 38 | played 3520 times
+	{0b01011111, 8},               // index 39 | played 2876 times
+	{0b000000011, 9},              // index 40 | played 2425 times
+	{0b001100011, 9},              // index 41 | played 2076 times
+	{0b010111100, 9},              // index 42 | played 1685 times
+	{0b0000000100, 10},            // index 43 | played 1389 times
+	{0b0011000100, 10},            // index 44 | played 1154 times
+	{0b0101111010, 10},            // index 45 | played 912 times
+	{0b0101111011, 10},            // index 46 | played 704 times
+	{0b00110001010, 11},           // index 47 | played 543 times
+	{0b00110001011, 11},           // index 48 | played 435 times
+	{0b000000010110, 12},          // index 49 | played 289 times
+	{0b000000010111, 12},          // index 50 | played 259 times
+	{0b0000000101001, 13},         // index 51 | played 178 times
+	{0b00000001010100, 14},        // index 52 | played 90 times
+	{0b00000001010001, 14},        // index 53 | played 105 times
+	{0b000000010100000, 15},       // index 54 | played 63 times
+	{0b000000010101010, 15},       // index 55 | played 39 times
+	{0b000000010101100, 15},       // index 56 | played 34 times
+	{0b00000001010101100, 17},     // index 57 | played 11 times
+	{0b000000010100001111, 18},    // index 58 | played 5 times
+	{0b00000001010101101, 17},     // index 59 | played 9 times
+	{0b00000001010000111011, 20},  // index 60 | played 1 times
+	{0b000000010101011101, 18},    // index 61 | played 4 times
+	{0b0000000101000010010, 19},   // index 62 | played 4 times
+	{0b000000010100001110100, 21}, // index 63 | played 1 times
+	{0b000000010100001110101, 21}, // index 64 | played 1 times
+	{0b000000010101011100, 18},    // index 65 | played 5 times
+	{0b000000010100001001110, 21}, // index 66 | played 1 times
+	{0b000000010100001001111, 21}, // index 67 | played 1 times
+	{0b000000010100001001100, 21}, // index 68 | played 1 times
+	{0b000000010100001001101, 21}, // index 69 | played 1 times
+	{0b000000010100001000010, 21}, // index 70 | played 1 times
+	{0b000000010100001000011, 21}, // index 71 | played 1 times
+	{0b000000010100001000000, 21}, // index 72 | played 1 times
+	{0b000000010100001000001, 21}, // index 73 | played 1 times
+	{0b000000010100001000110, 21}, // index 74 | played 1 times
+	{0b000000010100001000111, 21}, // index 75 | played 1 times
+	{0b000000010100001000100, 21}, // index 76 | played 1 times
+	{0b000000010100001000101, 21}, // index 77 | played 1 times
+	{0b000000010100001011010, 21}, // index 78 | played 1 times
+	{0b000000010100001011011, 21}, // index 79 | played 1 times
+	{0b000000010100001011000, 21}, // index 80 | played 1 times
+	{0b000000010100001011001, 21}, // index 81 | played 1 times
+	{0b000000010100001011110, 21}, // index 82 | played 1 times
+	{0b000000010100001011111, 21}, // index 83 | played 1 times
+	{0b000000010100001011100, 21}, // index 84 | played 1 times
+	{0b000000010100001011101, 21}, // index 85 | played 1 times
+	{0b000000010100001010010, 21}, // index 86 | played 1 times
+	{0b000000010100001010011, 21}, // index 87 | played 1 times
+	{0b000000010100001010000, 21}, // index 88 | played 1 times
+	{0b000000010100001010001, 21}, // index 89 | played 1 times
+	{0b000000010100001010110, 21}, // index 90 | played 1 times
+	{0b000000010100001010111, 21}, // index 91 | played 1 times
+	{0b000000010100001010100, 21}, // index 92 | played 1 times
+	{0b000000010100001010101, 21}, // index 93 | played 1 times
+	{0b00000001010111101010, 20},  // index 94 | played 1 times
+	{0b00000001010111101011, 20},  // index 95 | played 1 times
+	{0b00000001010111101000, 20},  // index 96 | played 1 times
+	{0b00000001010111101001, 20},  // index 97 | played 1 times
+	{0b00000001010111101110, 20},  // index 98 | played 1 times
+	{0b00000001010111101111, 20},  // index 99 | played 1 times
+	{0b00000001010111101100, 20},  // index 100 | played 1 times
+	{0b00000001010111101101, 20},  // index 101 | played 1 times
+	{0b00000001010111100010, 20},  // index 102 | played 1 times
+	{0b00000001010111100011, 20},  // index 103 | played 1 times
+	{0b00000001010111100000, 20},  // index 104 | played 1 times
+	{0b00000001010111100001, 20},  // index 105 | played 1 times
+	{0b00000001010111100110, 20},  // index 106 | played 1 times
+	{0b00000001010111100111, 20},  // index 107 | played 1 times
+	{0b00000001010111100100, 20},  // index 108 | played 1 times
+	{0b00000001010111100101, 20},  // index 109 | played 1 times
+	{0b00000001010111111010, 20},  // index 110 | played 1 times
+	{0b00000001010111111011, 20},  // index 111 | played 1 times
+	{0b00000001010111111000, 20},  // index 112 | played 1 times
+	{0b00000001010111111001, 20},  // index 113 | played 1 times
+	{0b00000001010111111110, 20},  // index 114 | played 1 times
+	{0b00000001010111111111, 20},  // index 115 | played 1 times
+	{0b00000001010111111100, 20},  // index 116 | played 1 times
+	{0b00000001010111111101, 20},  // index 117 | played 1 times
+	{0b00000001010111110010, 20},  // index 118 | played 1 times
+	{0b00000001010111110011, 20},  // index 119 | played 1 times
+	{0b00000001010111110000, 20},  // index 120 | played 1 times
+	{0b00000001010111110001, 20},  // index 121 | played 1 times
+	{0b00000001010111110110, 20},  // index 122 | played 1 times
+	{0b00000001010111110111, 20},  // index 123 | played 1 times
+	{0b00000001010111110100, 20},  // index 124 | played 1 times
+	{0b00000001010111110101, 20},  // index 125 | played 1 times
+	{0b00000001010111001010, 20},  // index 126 | played 1 times
+	{0b00000001010111001011, 20},  // index 127 | played 1 times
+	{0b00000001010111001000, 20},  // index 128 | played 1 times
+	{0b00000001010111001001, 20},  // index 129 | played 1 times
+	{0b00000001010111001110, 20},  // index 130 | played 1 times
+	{0b00000001010111001111, 20},  // index 131 | played 1 times
+	{0b00000001010111001100, 20},  // index 132 | played 1 times
+	{0b00000001010111001101, 20},  // index 133 | played 1 times
+	{0b00000001010111000010, 20},  // index 134 | played 1 times
+	{0b00000001010111000011, 20},  // index 135 | played 1 times
+	{0b00000001010111000000, 20},  // index 136 | played 1 times
+	{0b00000001010111000001, 20},  // index 137 | played 1 times
+	{0b00000001010111000110, 20},  // index 138 | played 1 times
+	{0b00000001010111000111, 20},  // index 139 | played 1 times
+	{0b00000001010111000100, 20},  // index 140 | played 1 times
+	{0b00000001010111000101, 20},  // index 141 | played 1 times
+	{0b00000001010111011010, 20},  // index 142 | played 1 times
+	{0b00000001010111011011, 20},  // index 143 | played 1 times
+	{0b00000001010111011000, 20},  // index 144 | played 1 times
+	{0b00000001010111011001, 20},  // index 145 | played 1 times
+	{0b00000001010111011110, 20},  // index 146 | played 1 times
+	{0b00000001010111011111, 20},  // index 147 | played 1 times
+	{0b00000001010111011100, 20},  // index 148 | played 1 times
+	{0b00000001010111011101, 20},  // index 149 | played 1 times
+	{0b00000001010111010010, 20},  // index 150 | played 1 times
+	{0b00000001010111010011, 20},  // index 151 | played 1 times
+	{0b00000001010111010000, 20},  // index 152 | played 1 times
+	{0b00000001010111010001, 20},  // index 153 | played 1 times
+	{0b00000001010111010110, 20},  // index 154 | played 1 times
+	{0b00000001010111010111, 20},  // index 155 | played 1 times
+	{0b00000001010111010100, 20},  // index 156 | played 1 times
+	{0b00000001010111010101, 20},  // index 157 | played 1 times
+	{0b00000001010110101010, 20},  // index 158 | played 1 times
+	{0b00000001010110101011, 20},  // index 159 | played 1 times
+	{0b00000001010110101000, 20},  // index 160 | played 1 times
+	{0b00000001010110101001, 20},  // index 161 | played 1 times
+	{0b00000001010110101110, 20},  // index 162 | played 1 times
+	{0b00000001010110101111, 20},  // index 163 | played 1 times
+	{0b00000001010110101100, 20},  // index 164 | played 1 times
+	{0b00000001010110101101, 20},  // index 165 | played 1 times
+	{0b00000001010110100010, 20},  // index 166 | played 1 times
+	{0b00000001010110100011, 20},  // index 167 | played 1 times
+	{0b00000001010110100000, 20},  // index 168 | played 1 times
+	{0b00000001010110100001, 20},  // index 169 | played 1 times
+	{0b00000001010110100110, 20},  // index 170 | played 1 times
+	{0b00000001010110100111, 20},  // index 171 | played 1 times
+	{0b00000001010110100100, 20},  // index 172 | played 1 times
+	{0b00000001010110100101, 20},  // index 173 | played 1 times
+	{0b00000001010110111010, 20},  // index 174 | played 1 times
+	{0b00000001010110111011, 20},  // index 175 | played 1 times
+	{0b00000001010110111000, 20},  // index 176 | played 1 times
+	{0b00000001010110111001, 20},  // index 177 | played 1 times
+	{0b00000001010110111110, 20},  // index 178 | played 1 times
+	{0b00000001010110111111, 20},  // index 179 | played 1 times
+	{0b00000001010110111100, 20},  // index 180 | played 1 times
+	{0b00000001010110111101, 20},  // index 181 | played 1 times
+	{0b00000001010110110010, 20},  // index 182 | played 1 times
+	{0b00000001010110110011, 20},  // index 183 | played 1 times
+	{0b00000001010110110000, 20},  // index 184 | played 1 times
+	{0b00000001010110110001, 20},  // index 185 | played 1 times
+	{0b00000001010110110110, 20},  // index 186 | played 1 times
+	{0b00000001010110110111, 20},  // index 187 | played 1 times
+	{0b00000001010110110100, 20},  // index 188 | played 1 times
+	{0b00000001010110110101, 20},  // index 189 | played 1 times
+	{0b000000010100001101010, 21}, // index 190 | played 1 times
+	{0b000000010100001101011, 21}, // index 191 | played 1 times
+	{0b000000010100001101000, 21}, // index 192 | played 1 times
+	{0b000000010100001101001, 21}, // index 193 | played 1 times
+	{0b000000010100001101110, 21}, // index 194 | played 1 times
+	{0b000000010100001101111, 21}, // index 195 | played 1 times
+	{0b000000010100001101100, 21}, // index 196 | played 1 times
+	{0b000000010100001101101, 21}, // index 197 | played 1 times
+	{0b000000010100001100010, 21}, // index 198 | played 1 times
+	{0b000000010100001100011, 21}, // index 199 | played 1 times
+	{0b000000010100001100000, 21}, // index 200 | played 1 times
+	{0b000000010100001100001, 21}, // index 201 | played 1 times
+	{0b000000010100001100110, 21}, // index 202 | played 1 times
+	{0b000000010100001100111, 21}, // index 203 | played 1 times
+	{0b000000010100001100100, 21}, // index 204 | played 1 times
+	{0b000000010100001100101, 21}, // index 205 | played 1 times
+	{0b00000001010101111010, 20},  // index 206 | played 1 times
+	{0b00000001010101111011, 20},  // index 207 | played 1 times
+	{0b00000001010101111000, 20},  // index 208 | played 1 times
+	{0b00000001010101111001, 20},  // index 209 | played 1 times
+	{0b00000001010101111110, 20},  // index 210 | played 1 times
+	{0b00000001010101111111, 20},  // index 211 | played 1 times
+	{0b00000001010101111100, 20},  // index 212 | played 1 times
+	{0b00000001010101111101, 20},  // index 213 | played 1 times
+	{0b000000010100001110010, 21}, // index 214 | played 1 times
+	{0b000000010100001110011, 21}, // index 215 | played 1 times
+	{0b000000010100001110000, 21}, // index 216 | played 1 times
+	{0b000000010100001110001, 21}, // index 217 | played 1 times
+}
+
+// huffmanNode is the node of the decoding tree.  Children are stored as indices
+// in the [huffmanTree].  Zero child means that the node is a leaf.
+type huffmanNode struct {
+	children [2]int
+	index    int
+}
+
+// huffmanTree is used to decode the Huffman codes bit by bit.  The root is
+// stored at index 0.
+var huffmanTree = initHuffmanTree()
+
+// Initializes the decoding tree from the [huffmanCodes].
+func initHuffmanTree() []huffmanNode {
+	tree := []huffmanNode{{}}
+
+	for i, c := range huffmanCodes {
+		n := 0
+		for bit := c.len - 1; bit >= 0; bit-- {
+			b := c.code >> bit & 1
+			if tree[n].children[b] == 0 {
+				tree = append(tree, huffmanNode{})
+				tree[n].children[b] = len(tree) - 1
+			}
+			n = tree[n].children[b]
+		}
+		tree[n].index = i
+	}
+	return tree
+}
+
+// EncodeGame encodes the sequence of moves played from the start position.  The
+// encoded game begins with the number of moves written as uvarint, followed by
+// the Huffman codes of the legal move indices.  Forced moves take zero bits.
+//
+// Returns an error wrapping [ErrIllegalMove] if the move is not legal in the
+// position in which it is played.
+func EncodeGame(moves []Move, start *Position) ([]byte, error) {
+	buf := binary.AppendUvarint(make([]byte, 0, len(moves)/2+8), uint64(len(moves)))
+
+	p := *start
+	var legal MoveList
+	// Number of bits written to the last byte of buf.
+	used := 8
+
+	for _, m := range moves {
+		GenLegalMoves(p, &legal)
+
+		index := -1
+		for i := range legal.Len {
+			if legal.Moves[i] == m {
+				index = int(i)
+				break
+			}
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("%w: %s", ErrIllegalMove, m.UCI())
+		}
+
+		if legal.Len > 1 {
+			c := huffmanCodes[index]
+			for bit := c.len - 1; bit >= 0; bit-- {
+				if used == 8 {
+					buf = append(buf, 0)
+					used = 0
+				}
+				buf[len(buf)-1] |= byte(c.code>>bit&1) << (7 - used)
+				used++
+			}
+		}
+
+		p.MakeMove(m)
+	}
+
+	return buf, nil
+}
+
+// DecodeGame decodes the game encoded by [EncodeGame] played from the start
+// position.
+//
+// Returns [ErrInvalidEncoding] if the data is truncated, encodes an illegal
+// move, or the move count cannot fit the data.
+func DecodeGame(data []byte, start *Position) ([]Move, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return nil, ErrInvalidEncoding
+	}
+	data = data[size:]
+	// Each move which is not forced takes at least one bit.
+	if n > uint64(8*len(data))+maxForcedMoves {
+		return nil, ErrInvalidEncoding
+	}
+
+	p := *start
+	var legal MoveList
+	moves := make([]Move, 0, min(n, 1024))
+	// Index of the next bit to read.
+	bit := 0
+
+	for range n {
+		GenLegalMoves(p, &legal)
+		if legal.Len == 0 {
+			return nil, ErrInvalidEncoding
+		}
+
+		index := 0
+		if legal.Len > 1 {
+			node := 0
+			for huffmanTree[node].children[0] != 0 {
+				// Stop as soon as the move is not forced and no bits are left.
+				if bit == 8*len(data) {
+					return nil, ErrInvalidEncoding
+				}
+				b := data[bit/8] >> (7 - bit%8) & 1
+				node = huffmanTree[node].children[b]
+				bit++
+			}
+			index = huffmanTree[node].index
+		}
+
+		if index >= int(legal.Len) {
+			return nil, ErrInvalidEncoding
+		}
+
+		m := legal.Moves[index]
+		moves = append(moves, m)
+		p.MakeMove(m)
+	}
+
+	// Only the padding of the last byte may remain.
+	if (bit+7)/8 != len(data) {
+		return nil, ErrInvalidEncoding
+	}
+	return moves, nil
+}
@@ -0,0 +1,109 @@
+package chego
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEncodeGame(t *testing.T) {
+	cases := []struct {
+		fen  string
+		sans []string
+	}{
+		{InitialPos, nil},
+		// Fool's mate.
+		{InitialPos, []string{"f3", "e5", "g4", "Qh4#"}},
+		// Castling, en passant, and promotion.
+		{InitialPos, []string{
+			"e4", "Nf6", "e5", "d5", "exd6", "Bf5", "dxc7", "Nc6", "cxd8=N",
+			"Kxd8", "Nf3", "e6", "Bb5", "Bc5", "O-O", "Re8",
+		}},
+		// Forced moves take zero bits.
+		{"7k/8/8/8/8/8/8/K6R b - - 0 1", []string{"Kg7", "Rh2", "Kg6"}},
+	}
+
+	for _, tc := range cases {
+		g := NewGame(tc.fen)
+		for _, san := range tc.sans {
+			if err := g.PushSAN(san); err != nil {
+				t.Fatalf("%s: %v", san, err)
+			}
+		}
+
+		moves := make([]Move, len(g.Moves))
+		for i, played := range g.Moves {
+			moves[i] = played.Move
+		}
+
+		data, err := EncodeGame(moves, ParseFen(tc.fen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := DecodeGame(data, ParseFen(tc.fen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, moves) {
+			t.Fatalf("expected %v, got %v", moves, got)
+		}
+	}
+}
+
+func TestEncodeGameIllegal(t *testing.T) {
+	start := ParseFen(InitialPos)
+	// The second move is played by white again.
+	moves := []Move{
+		NewMove(SE4, SE2, MoveNormal),
+		NewMove(SD4, SD2, MoveNormal),
+	}
+
+	if _, err := EncodeGame(moves, start); !errors.Is(err, ErrIllegalMove) {
+		t.Fatalf("expected ErrIllegalMove, got %v", err)
+	}
+}
+
+func TestDecodeGameInvalid(t *testing.T) {
+	start := ParseFen(InitialPos)
+	e4 := NewMove(SE4, SE2, MoveNormal)
+	data, err := EncodeGame([]Move{e4, NewMove(SE5, SE7, MoveNormal)}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := [][]byte{
+		nil,
+		// Truncated moves.
+		data[:1],
+		// Trailing bytes.
+		append(slices.Clone(data), 0),
+		// Move count exceeds the number of encoded moves.
+		append([]byte{3}, data[1:]...),
+		// Move count cannot fit the data.
+		append(binary.AppendUvarint(nil, 1<<40), data[1:]...),
+		binary.AppendUvarint(nil, 1<<62),
+	}
+
+	for _, tc := range cases {
+		if _, err := DecodeGame(tc, start); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("%v: expected ErrInvalidEncoding, got %v", tc, err)
+		}
+	}
+}
+
+func BenchmarkEncodeGame(b *testing.B) {
+	g := NewGame(InitialPos)
+	for _, san := range []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Ba4", "Nf6", "O-O", "Be7"} {
+		g.PushSAN(san)
+	}
+	moves := make([]Move, len(g.Moves))
+	for i, played := range g.Moves {
+		moves[i] = played.Move
+	}
+	start := ParseFen(InitialPos)
+
+	for b.Loop() {
+		EncodeGame(moves, start)
+	}
+}
@@ -3,8 +3,6 @@
 
 package chego
 
-import "math/rand/v2"
-
 // initBishopOccupancy initializes the lookup table of the "relevant occupancy
 // squares" for a bishop.  They are the only squares whose occupancy matters when
 // generating legal moves of a bishop.
@@ -155,31 +153,71 @@ func initKingAttacks() [64]uint64 {
 	return attacks
 }
 
-// Initializes the piece placement keys for the Zobrist hashing scheme.
+// Initializes the lookup tables of squares between and squares on the same line
+// with each pair of squares.  Both tables are empty for squares which are not on
+// the same rank, file, or diagonal.  Lines include both squares, while squares
+// between exclude them.
+func initLines() (between, lines [64][64]uint64) {
+	for a := range 64 {
+		for b := range 64 {
+			bbA, bbB := uint64(1<<a), uint64(1<<b)
+
+			if genRookAttacks(bbA, 0)&bbB != 0 {
+				between[a][b] = genRookAttacks(bbA, bbB) & genRookAttacks(bbB, bbA)
+				lines[a][b] = genRookAttacks(bbA, 0)&genRookAttacks(bbB, 0) | bbA | bbB
+			} else if genBishopAttacks(bbA, 0)&bbB != 0 {
+				between[a][b] = genBishopAttacks(bbA, bbB) & genBishopAttacks(bbB, bbA)
+				lines[a][b] = genBishopAttacks(bbA, 0)&genBishopAttacks(bbB, 0) | bbA | bbB
+			}
+		}
+	}
+	return between, lines
+}
+
+// Initializes the piece placement keys for the Zobrist hashing scheme.  Polyglot
+// orders the pieces with black first, so the color bit of each piece is flipped.
 func initPieceKeys() [12][64]uint64 {
 	var keys [12][64]uint64
 	for i := WPawn; i <= BKing; i++ {
 		for square := range 64 {
-			keys[i][square] = rand.Uint64()
+			keys[i][square] = polyglotRandoms[64*(i^1)+square]
 		}
 	}
 	return keys
 }
 
-// Initializes the en passant keys for the Zobrist hashing scheme.
-func initEnPassantKeys() [64]uint64 {
-	var keys [64]uint64
-	for square := range 64 {
-		keys[square] = rand.Uint64()
+// Initializes the en passant keys for the Zobrist hashing scheme.  Keys are
+// indexed by the file of the en passant target square.
+func initEnPassantKeys() [8]uint64 {
+	var keys [8]uint64
+	for file := range 8 {
+		keys[file] = polyglotRandoms[772+file]
 	}
 	return keys
 }
 
-// Initializes the caslting keys for the Zobrist hashing scheme.
+// Initializes the castling rights which are lost when a piece moves from or to
+// each square in the standard chess: the initial squares of the kings and the
+// rooks.
+func initCastlingMasks() (masks [64]CastlingRights) {
+	for i, rook := range standardCastlingRooks {
+		masks[rook] |= 1 << i
+	}
+	masks[SE1] |= CastlingWhiteShort | CastlingWhiteLong
+	masks[SE8] |= CastlingBlackShort | CastlingBlackLong
+	return masks
+}
+
+// Initializes the caslting keys for the Zobrist hashing scheme.  Each key is a
+// combination of the keys of the corresponding castling rights.
 func initCastlingKeys() [16]uint64 {
 	var keys [16]uint64
 	for i := range 16 {
-		keys[i] = rand.Uint64()
+		for right := range 4 {
+			if i&(1<<right) != 0 {
+				keys[i] ^= polyglotRandoms[768+right]
+			}
+		}
 	}
 	return keys
 }
@@ -227,18 +265,22 @@ var (
 
 	bishopAttacks = initBishopAttacks()
 	rookAttacks   = initRookAttacks()
+
+	// Squares between and lines through each pair of squares.  Used to
+	// resolve checks and pins.
+	betweenSquares, lineSquares = initLines()
 )
 
 // Zobrist Keys are used to hash each possible position into the unique number.
-// Each key is generated randomly and large enough, so the probability of hash
-// collisions is negligible.
+// Keys are taken from the [polyglotRandoms] and large enough, so the probability
+// of hash collisions is negligible.
 var (
 	pieceKeys = initPieceKeys()
-	// Used only when black is the active color.
+	// Used only when the en passant capture is possible.
 	epKeys       = initEnPassantKeys()
 	castlingKeys = initCastlingKeys()
-	// Used only when black is the active color.
-	colorKey = rand.Uint64()
+	// Used only when white is the active color.
+	colorKey = polyglotRandoms[780]
 )
 
 var (
@@ -10,74 +10,84 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"strings"
 	"sync"
 
 	"github.com/treepeck/chego"
+	"github.com/treepeck/chego/pgn"
 )
 
-var (
-	// PGN movetext may include move numbers and other tokens that are
-	// not useful for Huffman encoding. sanEx is used to extract only
-	// Standard Algebraic Notation tokens from the movetext.
-	sanEx = regexp.MustCompile(`([NBRQK]?[a-h]?[1-8]?x?[a-h][1-8](=[NBRQ])?[+#]?)|(O-O(-O)?[+#]?)`)
-	// PGN movetext may include annotations that need to be detected and removed.
-	// sanEx cannot remove annotations because they may be attached directly to
-	// the SAN move (e.g., e2??).
-	annotationEx = regexp.MustCompile(`[!?]{1,2}`)
-)
+// clean reads the PGN database from r and writes the main line SAN moves of
+// each game into the resulting file.  Each output line will contain a sequence
+// of SAN tokens, separated by a single whitespace.  All other PGN data, such as
+// comments and variations, will be trimmed.  Malformed games are skipped and
+// counted.
+func clean(r io.Reader, output *os.File) {
+	numGames, numSkipped := 0, 0
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+
+	// The games are split before parsing, so that the malformed one doesn't
+	// affect the rest of the database.
+	var game strings.Builder
+	hasMovetext := false
+	flush := func() {
+		defer game.Reset()
+		if strings.TrimSpace(game.String()) == "" {
+			return
+		}
 
-// clean reads from the specified reader line by line and writes valid SAN tokens
-// into the resulting file.  r reader must read a valid PGN database file.
-// Each output line will contain a sequence of SAN tokens, separated by a single
-// whitespace.  All other PGN data will be trimmed.
-//
-// Note: SAN tokens appearing inside comments are also recognized as valid moves.
-// Ensure that the input PGN file doesn't contain SAN moves within comments.
-func clean(r *bufio.Reader, output *os.File) {
-	for {
-		line, err := r.ReadString('\n')
+		g, err := pgn.Parse(game.String())
 		if err != nil {
-			break
+			numSkipped++
+			return
 		}
 
-		// Tag pairs are separated from the movetext by a single empty line.
-		if line == "\n" {
-			// Read movetext section.
-			var b strings.Builder
+		line := g.MainLine()
+		// If the game doesn't contain a single move, skip it.
+		if len(line) == 0 {
+			return
+		}
 
-			hasMoves := false
-			for {
-				movetext, err := r.ReadString('\n')
-				if err != nil || movetext == "\n" {
-					break
-				}
+		var b strings.Builder
+		for _, n := range line {
+			b.WriteString(n.SAN)
+			b.WriteByte(' ')
+		}
 
-				for token := range strings.SplitSeq(movetext, " ") {
-					// Trim '??', '!!', '?!', and '!?' annotations.
-					san := annotationEx.ReplaceAll([]byte(token),
-						[]byte(""))
-					if sanEx.Match(san) {
-						hasMoves = true
-						b.WriteString(string(san))
-						b.WriteByte(' ')
-					}
-				}
-			}
+		// Append new line to separate movetexts.
+		b.WriteByte('\n')
+		if _, err := output.WriteString(b.String()); err != nil {
+			panic(err)
+		}
+		numGames++
+	}
 
-			// If the game doesn't contain a single move, skip it.
-			if !hasMoves {
-				continue
-			}
+	for s.Scan() {
+		line := s.Text()
+		trimmed := strings.TrimSpace(line)
 
-			// Append new line to separate movetexts.
-			b.WriteByte('\n')
-			if _, err := output.WriteString(b.String()); err != nil {
-				panic(err)
+		// The tag pair after the movetext starts the next game.
+		if strings.HasPrefix(trimmed, "[") {
+			if hasMovetext {
+				flush()
+				hasMovetext = false
 			}
+		} else if trimmed != "" {
+			hasMovetext = true
 		}
+
+		game.WriteString(line)
+		game.WriteByte('\n')
 	}
+	if err := s.Err(); err != nil {
+		panic(err)
+	}
+	flush()
+
+	fmt.Printf("%d games written, %d malformed games skipped\n", numGames,
+		numSkipped)
 }
 
 // generate writes the array of generated Huffman codes to the output file.  r
@@ -122,7 +132,7 @@ func generate(r *bufio.Reader, output *os.File, workers int) {
 
 	numMoves := 0
 	for i := range 218 {
-		fmt.Fprintf(output, "{0b%s, %d}\t\t\t// index %d | played %d times\n",
+		fmt.Fprintf(output, "{0b%s, %d}, // index %d | played %d times\n",
 			codes[i], len(codes[i]), i, g.results[i])
 		numMoves += g.results[i]
 	}
@@ -174,25 +184,23 @@ func (g *generator) processGame() {
 		chego.GenLegalMoves(*pos, &ml)
 
 		for token := range strings.SplitSeq(movetext, " ") {
-			prev := pos
-			cml := ml
-			hasMatched := false
-
-			for i := range cml.Len {
-				pos = prev
-				ml = cml
+			m, err := chego.ParseSAN(token, pos)
+			if err != nil {
+				fmt.Printf("no match: %s in movetext %s\n", token, movetext)
+				break
+			}
 
-				if chego.Move2SAN(ml.Moves[i], pos, &ml) == token {
+			for i := range ml.Len {
+				if ml.Moves[i] == m {
 					g.Lock()
 					g.results[i]++
-					hasMatched = true
 					g.Unlock()
 					break
 				}
 			}
-			if !hasMatched {
-				fmt.Printf("no match: %s in movetext %s\n", token, movetext)
-			}
+
+			pos.MakeMove(m)
+			chego.GenLegalMoves(*pos, &ml)
 		}
 	}
 }
@@ -299,7 +307,7 @@ func main() {
 	defer outputFile.Close()
 
 	if *task == "clean" {
-		clean(bufio.NewReader(inputFile), outputFile)
+		clean(inputFile, outputFile)
 	} else {
 		generate(bufio.NewReader(inputFile), outputFile, *workers)
 	}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// TestEncode rebuilds the Huffman codes from the move frequencies recorded in
+// huffman.go and checks that the generated table was not edited by hand.
+func TestEncode(t *testing.T) {
+	src, err := os.ReadFile("../../huffman.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entryEx := regexp.MustCompile(
+		`\{0b([01]+), (\d+)\}, +// index (\d+) \| played (\d+) times`)
+	entries := entryEx.FindAllStringSubmatch(string(src), -1)
+	if len(entries) != 218 {
+		t.Fatalf("expected 218 codes, got %d", len(entries))
+	}
+
+	g := newGenerator()
+	expected := [218]string{}
+	numMoves := 0
+	for _, e := range entries {
+		index, _ := strconv.Atoi(e[3])
+		g.results[index], _ = strconv.Atoi(e[4])
+		expected[index] = e[1]
+		numMoves += g.results[index]
+
+		if length, _ := strconv.Atoi(e[2]); length != len(e[1]) {
+			t.Fatalf("index %d: code %s has length %d", index, e[1], length)
+		}
+	}
+
+	// The frequencies must add up to the size of the stated corpus.
+	total := regexp.MustCompile(`(\d+) moves in total`).FindStringSubmatch(string(src))
+	if total == nil || total[1] != strconv.Itoa(numMoves) {
+		t.Fatalf("frequencies add up to %d moves, documented %v", numMoves, total)
+	}
+
+	codes := g.encode()
+	for i := range codes {
+		if codes[i] != expected[i] {
+			t.Fatalf("index %d: expected %s, got %s", i, expected[i], codes[i])
+		}
+	}
+}
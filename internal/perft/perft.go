@@ -1,23 +1,24 @@
 // peft.go implements debugging and testing functions for the move generator.
 //
 // It is internal, as it is only used for testing purposes.
-//
-// TODO: fix verbose perft.  It doesn't print the resulting information correctly.
 
 package main
 
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"runtime/pprof"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/treepeck/chego"
 )
 
 // result information is printed to the console when the verbose flag is used.
+// All counters, except for nodes, only take into account the leaf nodes.
 type result struct {
 	nodes        int
 	captures     int
@@ -29,159 +30,111 @@ type result struct {
 	checkmates   int
 }
 
-// perft is a debugging function that walks through the move generation tree of
-// strictly legal moves to a given depth and counts the number of visited leaf
-// nodes. The resulting count is then compared to predetermined values.
-//
-// See https://www.chessprogramming.org/Perft_Results
-func perft(p chego.Position, depth int) int {
+// divide runs perft for each legal move of the root position and writes the
+// node count of each move in UCI notation to w.  Use this function to find the
+// branch of the move generation tree which disagrees with the reference.
+func divide(p *chego.Position, depth int, opts chego.PerftOptions, w io.Writer) int {
 	l := chego.MoveList{}
 	nodes := 0
 
-	chego.GenLegalMoves(p, &l)
-
-	if depth == 1 {
-		return int(l.Len)
-	}
-
-	var prev chego.Position
-	var moved, captured chego.Piece
+	chego.GenLegalMoves(*p, &l)
 
 	for i := range l.Len {
-		prev = p
-		moved = p.GetPieceFromSquare(1 << l.Moves[i].From())
-		captured = p.GetPieceFromSquare(1 << l.Moves[i].To())
-		p.MakeMove(l.Moves[i], moved, captured)
+		cnt := 1
+		if depth > 1 {
+			u := p.MakeMove(l.Moves[i])
+
+			cnt = int(chego.Perft(*p, depth-1, opts))
 
-		nodes += perft(p, depth-1)
+			p.UnmakeMove(l.Moves[i], u)
+		}
 
-		p = prev
+		fmt.Fprintf(w, "%s: %d\n", l.Moves[i].UCI(), cnt)
+		nodes += cnt
 	}
 
 	return nodes
 }
 
-// perftVerbose follows the same principle as the perft function, except it
-// writes detailed move debugging information to r. Use this function to debug
-// and find invalid branches in the move generation tree, not to measure
+// perftVerbose follows the same principle as the chego.Perft function, except it
+// collects detailed move debugging information into r. Use this function to
+// debug and find invalid branches in the move generation tree, not to measure
 // performance.
-func perftVerbose(p chego.Position, depth int, r *result, isRoot bool) int {
+func perftVerbose(p *chego.Position, depth int, r *result) int {
 	l := chego.MoveList{}
 	nodes := 0
 
-	chego.GenLegalMoves(p, &l)
-
-	if depth == 1 {
-		return int(l.Len)
-	}
+	chego.GenLegalMoves(*p, &l)
 
 	c := p.ActiveColor
-	var prev chego.Position
-	var moved, captured chego.Piece
 
 	for i := range l.Len {
-		if p.GetPieceFromSquare(1<<l.Moves[i].To()) != chego.PieceNone {
-			r.captures++
-		}
+		m := l.Moves[i]
+		u := p.MakeMove(m)
 
-		prev = p
-		moved = p.GetPieceFromSquare(1 << l.Moves[i].From())
-		captured = p.GetPieceFromSquare(1 << l.Moves[i].To())
-		p.MakeMove(l.Moves[i], moved, captured)
-
-		cnt := chego.GenChecksCounter(p.Bitboards, 1^c)
-		if cnt > 0 {
-			r.checks++
-		}
-		if cnt > 1 {
-			r.doubleChecks++
+		if depth > 1 {
+			nodes += perftVerbose(p, depth-1, r)
+			p.UnmakeMove(m, u)
+			continue
 		}
 
-		cnt = perftVerbose(p, depth-1, r, false)
-		if isRoot {
-			fmt.Printf("%s %d\n", move2UCI(l.Moves[i]), cnt)
-		}
-		nodes += cnt
+		nodes++
 
-		switch l.Moves[i].Type() {
+		switch m.Type() {
 		case chego.MoveCastling:
 			r.castles++
 		case chego.MoveEnPassant:
+			r.captures++
 			r.epCaptures++
 		case chego.MovePromotion:
 			r.promotions++
 		}
+		// Undo does not store the own rook captured by the king in Chess960
+		// castling.
+		if u.Captured != chego.PieceNone {
+			r.captures++
+		}
 
-		p = prev
-	}
-
-	return nodes
-}
+		cnt := chego.GenChecksCounter(p.Bitboards, c)
+		if cnt > 0 {
+			r.checks++
 
-// move2UCI converts the move into a long algebraic notation string.
-//
-// Examples: e2e4, e7e5, e1g1 (white short castling), e7e8q (for promotion).
-func move2UCI(m chego.Move) string {
-	var b strings.Builder
-	b.Grow(4)
-
-	b.WriteString(chego.Square2String[m.From()])
-	b.WriteString(chego.Square2String[m.To()])
-
-	if m.Type() == chego.MovePromotion {
-		switch m.PromoPiece() {
-		case chego.PromotionKnight:
-			b.WriteByte('n')
-		case chego.PromotionBishop:
-			b.WriteByte('b')
-		case chego.PromotionRook:
-			b.WriteByte('r')
-		case chego.PromotionQueen:
-			b.WriteByte('q')
+			replies := chego.MoveList{}
+			chego.GenLegalMoves(*p, &replies)
+			if replies.Len == 0 {
+				r.checkmates++
+			}
 		}
+		if cnt > 1 {
+			r.doubleChecks++
+		}
+
+		p.UnmakeMove(m, u)
 	}
 
-	return b.String()
+	return nodes
 }
 
 // main runs the perft and measures it's execution time.
 func main() {
 	depth := flag.Int("depth", 1, "Performance test depth")
+	fen := flag.String("fen", chego.InitialPos, "Root position")
+	divideFlag := flag.Bool("divide", false, "Wether to print the node count of each root move")
 	verbose := flag.Bool("verbose", false, "Wether to print the debug info")
+	workers := flag.Int("workers", 0, "Number of goroutines, defaults to the number of CPUs")
+	hash := flag.Int("hash", 0, "Size of the transposition table in megabytes")
+	suite := flag.String("suite", "", "EPD file with the expected node counts; every depth is checked unless -depth is set")
 	cpuprofile := flag.String("cpuprofile", "", "File to write a cpu profile")
 	memprofile := flag.String("memprofile", "", "File to write a memory profile")
 
 	flag.Parse()
 
-	r := &result{}
-
-	fen := chego.InitialPos
-	p := chego.ParseFen(fen)
+	if *depth < 1 {
+		fmt.Fprintln(os.Stderr, "depth must be positive")
+		os.Exit(2)
+	}
 
-	start := time.Now()
-	defer func() {
-		elapsed := time.Since(start)
-
-		if *verbose {
-			fmt.Printf("\nRoot position:\n%s\n\n\t%s\n\n", position(*p), fen)
-			fmt.Printf("\t%d\t%d\t\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t",
-				*depth,
-				r.nodes,
-				r.captures,
-				r.epCaptures,
-				r.castles,
-				r.promotions,
-				r.checks,
-				r.doubleChecks,
-				r.checkmates,
-			)
-			fmt.Printf("Elapsed time: %d ns\n", elapsed.Nanoseconds())
-		} else {
-
-			fmt.Printf("Nodes reached: %d\n", r.nodes)
-			fmt.Printf("Elapsed time: %d ns\n", elapsed.Nanoseconds())
-		}
-	}()
+	opts := chego.PerftOptions{Workers: *workers, HashMB: *hash}
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -196,15 +149,92 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		pprof.WriteHeapProfile(f)
+		defer func() {
+			pprof.WriteHeapProfile(f)
+			f.Close()
+		}()
+	}
+
+	if *suite != "" {
+		f, err := os.Open(*suite)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
 		defer f.Close()
+
+		// Without the explicit depth the suite is run to its full depth.
+		maxDepth := 0
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "depth" {
+				maxDepth = *depth
+			}
+		})
+
+		start := time.Now()
+		mismatches, err := runSuite(f, maxDepth, opts, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("Elapsed time: %d ns\n", time.Since(start).Nanoseconds())
+		if mismatches > 0 {
+			// Deferred functions are not run by os.Exit.
+			pprof.StopCPUProfile()
+			os.Exit(1)
+		}
+		return
+	}
+
+	p, err := chego.ParseFENStrict(*fen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
+	r := &result{}
+	start := time.Now()
+
+	switch {
+	case *verbose:
+		r.nodes = perftVerbose(p, *depth, r)
+	case *divideFlag:
+		r.nodes = divide(p, *depth, opts, os.Stdout)
+		fmt.Println()
+	default:
+		r.nodes = int(chego.Perft(*p, *depth, opts))
+	}
+
+	elapsed := time.Since(start)
+
 	if *verbose {
-		r.nodes = perftVerbose(*p, *depth, r, true)
+		fmt.Printf("Root position:\n%s\n\n\t%s\n\n", position(*p), *fen)
+		printResult(os.Stdout, *depth, r)
+		fmt.Println()
 	} else {
-		r.nodes = perft(*p, *depth)
+		fmt.Printf("Nodes reached: %d\n", r.nodes)
 	}
+	fmt.Printf("Elapsed time: %d ns\n", elapsed.Nanoseconds())
+}
+
+// printResult writes the table with the perft results in the same format as
+// the Chess Programming Wiki.
+func printResult(w io.Writer, depth int, r *result) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Depth\tNodes\tCaptures\tE.p.\tCastles\tPromotions\tChecks\t"+
+		"Double checks\tCheckmates\t")
+	fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+		depth,
+		r.nodes,
+		r.captures,
+		r.epCaptures,
+		r.castles,
+		r.promotions,
+		r.checks,
+		r.doubleChecks,
+		r.checkmates,
+	)
+	tw.Flush()
 }
 
 // position formats a full chess position into a string.
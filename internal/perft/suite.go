@@ -0,0 +1,61 @@
+// suite.go implements running of the perft test suites.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/treepeck/chego"
+	"github.com/treepeck/chego/epd"
+)
+
+// runSuite runs perft for each position of the suite up to the specified depth,
+// or for every depth of the suite if maxDepth is zero, and writes every mismatch
+// to w.  The suite is an EPD file, where the expected node counts are stored in
+// the "D1" to "Dn" operations:
+//
+//	rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - ;D1 20 ;D2 400
+//
+// Empty lines and lines starting with '#' are skipped.  Returns the number of
+// mismatches.
+func runSuite(r io.Reader, maxDepth int, opts chego.PerftOptions, w io.Writer) (int, error) {
+	reader := epd.NewReader(r)
+	positions, mismatches := 0, 0
+
+	for {
+		rec, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return mismatches, err
+		}
+		n := reader.Line()
+
+		nodes, err := rec.Perft()
+		if err != nil {
+			return mismatches, fmt.Errorf("line %d: %w", n, err)
+		}
+		positions++
+
+		for _, depth := range slices.Sorted(maps.Keys(nodes)) {
+			if maxDepth > 0 && depth > maxDepth {
+				break
+			}
+
+			expected := nodes[depth]
+			if got := chego.Perft(rec.Position, depth, opts); got != expected {
+				fmt.Fprintf(w, "line %d: %s: depth %d: expected %d, got %d\n",
+					n, chego.SerializeFen(&rec.Position), depth, expected, got)
+				mismatches++
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Positions: %d, mismatches: %d\n", positions, mismatches)
+	return mismatches, nil
+}
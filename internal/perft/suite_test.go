@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestRunSuite(t *testing.T) {
+	suite := `# Initial position with a wrong count at depth 3.
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - ;D1 20 ;D2 400 ;D3 8903
+
+r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1 ;D1 48 ;D2 2039 ;D5 1
+`
+	var out bytes.Buffer
+	mismatches, err := runSuite(strings.NewReader(suite), 3, chego.PerftOptions{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatches != 1 {
+		t.Fatalf("expected 1 mismatch, got %d:\n%s", mismatches, out.String())
+	}
+	if !strings.Contains(out.String(), "line 2: ") ||
+		!strings.Contains(out.String(), "depth 3: expected 8903, got 8902") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	// Without the limit every depth is checked.
+	out.Reset()
+	suite = "8/8/8/8/8/8/8/K6k w - - ;D1 3 ;D5 1\n"
+	mismatches, err = runSuite(strings.NewReader(suite), 0, chego.PerftOptions{}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mismatches != 1 || !strings.Contains(out.String(), "depth 5: expected 1, ") {
+		t.Fatalf("expected 1 mismatch, got %d:\n%s", mismatches, out.String())
+	}
+}
+
+func TestRunSuiteInvalid(t *testing.T) {
+	cases := []string{
+		"8/8/8/8/8/8/8/K6k w - ;D1 3",
+		"8/8/8/8/8/8/8/K6k w - - ;D1",
+		"8/8/8/8/8/8/8/K6k w - - ;D1 x",
+	}
+
+	for _, tc := range cases {
+		var out bytes.Buffer
+		if _, err := runSuite(strings.NewReader(tc), 0, chego.PerftOptions{}, &out); err == nil {
+			t.Fatalf("%s: expected error", tc)
+		}
+	}
+}
+
+func TestDivide(t *testing.T) {
+	p := chego.ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	var out bytes.Buffer
+	if nodes := divide(p, 2, chego.PerftOptions{HashMB: 1}, &out); nodes != 2039 {
+		t.Fatalf("expected 2039 nodes, got %d", nodes)
+	}
+	if !strings.Contains(out.String(), "e1g1: 43\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
@@ -70,38 +70,139 @@ func (l *MoveList) Push(m Move) {
 	l.Len++
 }
 
-// GenLegalMoves generates legal moves for the given position using copy-make
-// approach.
+// Kinds of the moves generated by [genLegalMoves].
+const (
+	// Captures, including en passant, and promotions.
+	genCaptures = 1 << iota
+	// Quiet moves, including castling.
+	genQuiets
+	// Generate moves only if the king is in check.
+	genEvasions
+	genAll = genCaptures | genQuiets
+)
+
+// GenLegalMoves generates legal moves for the given position.
+//
+// Checkers and pinned pieces are calculated up front, so only legal moves are
+// generated:
+//   - In double check only the king can move;
+//   - In single check other pieces can only capture the checker or block the
+//     check;
+//   - Pinned pieces can only move along the line between the king and the
+//     pinner.
+//
+// Moves are generated in the same order in which the pseudo-legal moves would
+// be generated, see the WARN above.
 func GenLegalMoves(p Position, l *MoveList) {
+	genLegalMoves(p, l, genAll)
+}
+
+// GenLegalCaptures generates legal captures, including en passant, and
+// promotions for the given position.  Useful for the quiescence search.
+//
+// Together with [GenLegalQuiets] it generates exactly the moves of
+// [GenLegalMoves], in the same relative order.
+func GenLegalCaptures(p Position, l *MoveList) {
+	genLegalMoves(p, l, genCaptures)
+}
+
+// GenLegalQuiets generates legal moves which neither capture nor promote,
+// including castling, for the given position.
+func GenLegalQuiets(p Position, l *MoveList) {
+	genLegalMoves(p, l, genQuiets)
+}
+
+// GenLegalEvasions generates legal moves which get the king out of check.  If
+// the king is not in check, the move list will be empty.  Otherwise, the moves
+// are the same as generated by [GenLegalMoves].
+func GenLegalEvasions(p Position, l *MoveList) {
+	genLegalMoves(p, l, genAll|genEvasions)
+}
+
+// genLegalMoves generates legal moves of the specified kind.
+func genLegalMoves(p Position, l *MoveList, kind int) {
 	l.Len = 0
 
-	genKingMoves(p, l)
+	c := p.ActiveColor
+	king := bitScan(p.Bitboards[WKing+c])
 
-	if GenChecksCounter(p.Bitboards, 1^p.ActiveColor) > 2 {
+	checkers := p.AttackersTo(king, p.Bitboards[14]) & p.Bitboards[12+(1^c)]
+	if kind&genEvasions != 0 && checkers == 0 {
 		return
 	}
 
-	pseudoLegal := MoveList{}
+	genKingMoves(p, l, kind)
 
-	genPawnMoves(p, &pseudoLegal)
+	// Only the king can move in double check.
+	if checkers&(checkers-1) != 0 {
+		return
+	}
 
-	genNormalMoves(p, &pseudoLegal)
+	// Squares to which pieces can move to capture the checker or block the
+	// check.
+	checkMask := uint64(ALL_SQUARES)
+	if checkers != 0 {
+		checkMask = betweenSquares[king][bitScan(checkers)] | checkers
+	}
 
-	prev := p
-	var moved, captured Piece
+	pinned := genPinned(p, king)
 
-	for i := range pseudoLegal.Len {
-		moved = p.GetPieceFromSquare(1 << pseudoLegal.Moves[i].From())
-		captured = p.GetPieceFromSquare(1 << pseudoLegal.Moves[i].To())
+	genPawnMoves(p, l, checkMask, pinned, kind)
 
-		p.MakeMove(pseudoLegal.Moves[i], moved, captured)
+	genNormalMoves(p, l, checkMask, pinned, kind)
+}
 
-		if GenChecksCounter(p.Bitboards, p.ActiveColor) == 0 {
-			l.Push(pseudoLegal.Moves[i])
-		}
+// genTargets returns the destination squares of the moves of the specified
+// kind.  En passant and promotions are handled separately.
+func genTargets(p Position, kind int) (targets uint64) {
+	if kind&genCaptures != 0 {
+		targets |= p.Bitboards[12+(1^p.ActiveColor)]
+	}
+	if kind&genQuiets != 0 {
+		targets |= ^p.Bitboards[14]
+	}
+	return targets
+}
 
-		p = prev
+// AttackersTo returns a bitboard of pieces of both colors which attack the
+// specified square.  Only pieces present in the specified occupancy are taken
+// into account and slider attacks are blocked by it, so removing pieces from
+// the occupancy reveals x-ray attackers behind them.
+func (p *Position) AttackersTo(square int, occupancy uint64) uint64 {
+	bishops := p.Bitboards[WBishop] | p.Bitboards[BBishop] |
+		p.Bitboards[WQueen] | p.Bitboards[BQueen]
+	rooks := p.Bitboards[WRook] | p.Bitboards[BRook] |
+		p.Bitboards[WQueen] | p.Bitboards[BQueen]
+
+	return (pawnAttacks[ColorBlack][square]&p.Bitboards[WPawn] |
+		pawnAttacks[ColorWhite][square]&p.Bitboards[BPawn] |
+		knightAttacks[square]&(p.Bitboards[WKnight]|p.Bitboards[BKnight]) |
+		kingAttacks[square]&(p.Bitboards[WKing]|p.Bitboards[BKing]) |
+		lookupBishopAttacks(square, occupancy)&bishops |
+		lookupRookAttacks(square, occupancy)&rooks) & occupancy
+}
+
+// genPinned returns a bitboard of pieces of the active color which are pinned
+// to their king.
+func genPinned(p Position, king int) (pinned uint64) {
+	c := p.ActiveColor
+	enemies := p.Bitboards[12+(1^c)]
+
+	// Find enemy sliders which would attack the king if there were no allied
+	// pieces in between.
+	pinners := lookupBishopAttacks(king, enemies) &
+		(p.Bitboards[WBishop+(1^c)] | p.Bitboards[WQueen+(1^c)])
+	pinners |= lookupRookAttacks(king, enemies) &
+		(p.Bitboards[WRook+(1^c)] | p.Bitboards[WQueen+(1^c)])
+
+	for pinners > 0 {
+		blockers := betweenSquares[king][popLSB(&pinners)] & p.Bitboards[14]
+		// The piece is pinned if it is the only blocker.
+		if blockers != 0 && blockers&(blockers-1) == 0 {
+			pinned |= blockers
+		}
 	}
+	return pinned
 }
 
 // GenChecksCounter returns the number of the pieces of the specified color that
@@ -132,47 +233,68 @@ func GenChecksCounter(bitboards [15]uint64, c Color) (cnt int) {
 	return cnt
 }
 
-// genKingMoves appends legal moves for the king on the given position to the
-// specified move list.  Handles special king move - castling.
-func genKingMoves(p Position, l *MoveList) {
+// genKingMoves appends legal moves of the specified kind for the king on the
+// given position to the specified move list.  Handles special king move -
+// castling.
+func genKingMoves(p Position, l *MoveList, kind int) {
 	kingBB := p.Bitboards[WKing+p.ActiveColor]
 	p.removePiece(WKing+p.ActiveColor, kingBB)
 	attacks := genAttacks(p.Bitboards, 1^p.ActiveColor)
-	p.removePiece(WKing+p.ActiveColor, kingBB)
+	p.placePiece(WKing+p.ActiveColor, kingBB)
 	king := bitScan(kingBB)
 
-	dests := kingAttacks[king] & (^attacks) & (^p.Bitboards[12+p.ActiveColor])
+	dests := kingAttacks[king] & (^attacks) & genTargets(p, kind)
 
 	for dests > 0 {
 		l.Push(NewMove(popLSB(&dests), king, MoveNormal))
 	}
 
+	if kind&genQuiets == 0 {
+		return
+	}
+
 	p.Bitboards[14] ^= kingBB
-	// Handle castling.
-	if p.ActiveColor == ColorWhite {
-		if p.canCastle(CastlingWhiteShort, attacks, p.Bitboards[14]) &&
-			p.Bitboards[WRook]&H1 != 0 {
-			l.Push(NewMove(SG1, king, MoveCastling))
+	// Handle castling.  O-O is generated before O-O-O.
+	c := p.ActiveColor
+	for i := 2 * c; i < 2*c+2; i++ {
+		rook := p.castlingRook(i)
+		if p.CastlingRights&(1<<i) == 0 {
+			continue
 		}
-		if p.canCastle(CastlingWhiteLong, attacks, p.Bitboards[14]) &&
-			p.Bitboards[WRook]&A1 != 0 {
-			l.Push(NewMove(SC1, king, MoveCastling))
+
+		kingDest, rookDest := castlingKingDest[i], castlingRookDest[i]
+		// Squares which the king and the rook pass, including their
+		// destinations.  In Chess960 they may already stand on them.
+		kingPath := betweenSquares[king][kingDest] | 1<<kingDest
+		rookPath := betweenSquares[rook][rookDest] | 1<<rookDest
+
+		if (kingPath|rookPath)&^(1<<rook)&p.Bitboards[14] != 0 ||
+			(kingPath|kingBB)&attacks != 0 {
+			continue
 		}
-	} else {
-		if p.canCastle(CastlingBlackShort, attacks, p.Bitboards[14]) &&
-			p.Bitboards[BRook]&H8 != 0 {
-			l.Push(NewMove(SG8, king, MoveCastling))
+
+		// In Chess960 the castling rook may shield the king's destination
+		// from the enemy rook or queen standing on the same rank.
+		occupancy := p.Bitboards[14] ^ 1<<rook | 1<<kingDest | 1<<rookDest
+		if lookupRookAttacks(kingDest, occupancy)&(p.Bitboards[WRook+(1^c)]|
+			p.Bitboards[WQueen+(1^c)]) != 0 {
+			continue
 		}
-		if p.canCastle(CastlingBlackLong, attacks, p.Bitboards[14]) &&
-			p.Bitboards[BRook]&A8 != 0 {
-			l.Push(NewMove(SC8, king, MoveCastling))
+
+		if p.Chess960 {
+			l.Push(NewMove(rook, king, MoveCastling))
+		} else {
+			l.Push(NewMove(kingDest, king, MoveCastling))
 		}
 	}
 }
 
-// genPawnMoves appends pseudo-legal moves for a pawns to the given move list.
-// Handles special pawn move - en passant.
-func genPawnMoves(p Position, l *MoveList) {
+// genPawnMoves appends legal moves of the specified kind for a pawns to the given
+// move list.  Handles special pawn move - en passant.
+//
+// Destination squares are restricted by the checkMask, and moves of pinned
+// pawns are restricted to the pin line.
+func genPawnMoves(p Position, l *MoveList, checkMask, pinned uint64, kind int) {
 	occupancy := p.Bitboards[14]
 	ep := uint64(0)
 	if p.EPTarget > 0 {
@@ -180,6 +302,7 @@ func genPawnMoves(p Position, l *MoveList) {
 	}
 	enemies := p.Bitboards[12+(1^p.ActiveColor)]
 	pawns := p.Bitboards[WPawn+p.ActiveColor]
+	king := bitScan(p.Bitboards[WKing+p.ActiveColor])
 
 	// Determine movement direction.
 	dir, initRank, promoRank := 8, rank2, rank8
@@ -193,29 +316,42 @@ func genPawnMoves(p Position, l *MoveList) {
 		pawn := popLSB(&pawns)
 		square := uint64(1 << pawn)
 
+		// Squares to which the pawn can move.
+		mask := checkMask
+		if square&pinned != 0 {
+			mask &= lineSquares[king][pawn]
+		}
+
 		fwd, dblFwd := pawn+dir, pawn+2*dir
 		// If the pawn can move forward.
 		fwdBB := uint64(1 << fwd)
 		if fwdBB&occupancy == 0 {
-			// Check if the move is promotion.
-			if fwdBB&promoRank != 0 {
-				l.Push(NewPromotionMove(fwd, pawn, PromotionKnight))
-				l.Push(NewPromotionMove(fwd, pawn, PromotionBishop))
-				l.Push(NewPromotionMove(fwd, pawn, PromotionRook))
-				l.Push(NewPromotionMove(fwd, pawn, PromotionQueen))
-			} else {
-				l.Push(NewMove(fwd, pawn, MoveNormal))
+			if fwdBB&mask != 0 {
+				// Check if the move is promotion.
+				if fwdBB&promoRank != 0 && kind&genCaptures != 0 {
+					l.Push(NewPromotionMove(fwd, pawn, PromotionKnight))
+					l.Push(NewPromotionMove(fwd, pawn, PromotionBishop))
+					l.Push(NewPromotionMove(fwd, pawn, PromotionRook))
+					l.Push(NewPromotionMove(fwd, pawn, PromotionQueen))
+				} else if fwdBB&promoRank == 0 && kind&genQuiets != 0 {
+					l.Push(NewMove(fwd, pawn, MoveNormal))
+				}
 			}
 			// If the pawn is standing on its initial rank and can move
 			// double forward.
-			if square&initRank != 0 && 1<<dblFwd&occupancy == 0 {
+			if square&initRank != 0 && 1<<dblFwd&(occupancy|^mask) == 0 &&
+				kind&genQuiets != 0 {
 				l.Push(NewMove(dblFwd, pawn, MoveNormal))
 			}
 		}
 
+		if kind&genCaptures == 0 {
+			continue
+		}
+
 		// Handle pawn attacks.  Pawn can only capture enemy pieces
 		// or the en passant target square.
-		attacks := pawnAttacks[p.ActiveColor][pawn] & (enemies | ep)
+		attacks := pawnAttacks[p.ActiveColor][pawn] & (enemies&mask | ep)
 		for attacks > 0 {
 			to := popLSB(&attacks)
 			// Handle capture promotion.
@@ -225,7 +361,9 @@ func genPawnMoves(p Position, l *MoveList) {
 				l.Push(NewPromotionMove(to, pawn, PromotionRook))
 				l.Push(NewPromotionMove(to, pawn, PromotionQueen))
 			} else if 1<<to&ep != 0 {
-				l.Push(NewMove(to, pawn, MoveEnPassant))
+				if isLegalEnPassant(p, pawn, to, king, checkMask) {
+					l.Push(NewMove(to, pawn, MoveEnPassant))
+				}
 			} else {
 				l.Push(NewMove(to, pawn, MoveNormal))
 			}
@@ -233,12 +371,40 @@ func genPawnMoves(p Position, l *MoveList) {
 	}
 }
 
-// genPawnMoves appends pseudo-legal moves for knights, bishops, rooks, and
-// queens to the given move list.
-func genNormalMoves(p Position, l *MoveList) {
+// isLegalEnPassant checks whether the en passant capture leaves the king in
+// check.  En passant removes two pieces from the same rank, so the pins cannot
+// be used here.  Instead, the slider attacks to the king are recalculated after
+// the capture.
+func isLegalEnPassant(p Position, from, to, king int, checkMask uint64) bool {
 	c := p.ActiveColor
-	allies := p.Bitboards[12+c]
+	captured := uint64(1 << (to - 8))
+	if c == ColorBlack {
+		captured = 1 << (to + 8)
+	}
+
+	// The capture must either remove the checker or block the check.
+	if (captured|1<<to)&checkMask == 0 {
+		return false
+	}
+
+	occupancy := p.Bitboards[14] ^ (1 << from) ^ captured | 1<<to
+
+	return lookupBishopAttacks(king, occupancy)&(p.Bitboards[WBishop+(1^c)]|
+		p.Bitboards[WQueen+(1^c)]) == 0 &&
+		lookupRookAttacks(king, occupancy)&(p.Bitboards[WRook+(1^c)]|
+			p.Bitboards[WQueen+(1^c)]) == 0
+}
+
+// genNormalMoves appends legal moves of the specified kind for knights, bishops,
+// rooks, and queens to the given move list.
+//
+// Destination squares are restricted by the checkMask, and moves of pinned
+// pieces are restricted to the pin line.
+func genNormalMoves(p Position, l *MoveList, checkMask, pinned uint64, kind int) {
+	c := p.ActiveColor
+	targets := genTargets(p, kind) & checkMask
 	occupancy := p.Bitboards[14]
+	king := bitScan(p.Bitboards[WKing+c])
 
 	for i := WKnight + c; i <= WQueen+c; i += 2 {
 		pieces := p.Bitboards[i]
@@ -257,7 +423,10 @@ func genNormalMoves(p Position, l *MoveList) {
 				dests |= lookupQueenAttacks(from, occupancy)
 			}
 
-			dests &= ^allies
+			dests &= targets
+			if 1<<from&pinned != 0 {
+				dests &= lineSquares[king][from]
+			}
 			for dests > 0 {
 				l.Push(NewMove(popLSB(&dests), from, MoveNormal))
 			}
@@ -1,9 +1,180 @@
 package chego
 
 import (
+	"slices"
 	"testing"
 )
 
+// perft counts the leaf nodes of the legal move tree of the specified depth.
+func perft(p *Position, depth int) int {
+	var l MoveList
+	GenLegalMoves(*p, &l)
+
+	if depth == 1 {
+		return int(l.Len)
+	}
+
+	nodes := 0
+	for i := range l.Len {
+		m := l.Moves[i]
+		u := p.MakeMove(m)
+		nodes += perft(p, depth-1)
+		p.UnmakeMove(m, u)
+	}
+	return nodes
+}
+
+// TestPerft compares the number of leaf nodes with the known values.
+//
+// See https://www.chessprogramming.org/Perft_Results
+func TestPerft(t *testing.T) {
+	cases := []struct {
+		fen      string
+		depth    int
+		expected int
+	}{
+		{InitialPos, 4, 197281},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 3, 97862},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 4, 43238},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 3, 9467},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 3, 62379},
+		{"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", 3, 89890},
+		// Chess960.
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", 4, 326672},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", 4, 667366},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", 4, 273318},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", 4, 382958},
+		{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", 4, 1171749},
+	}
+
+	for _, tc := range cases {
+		if got := perft(ParseFen(tc.fen), tc.depth); got != tc.expected {
+			t.Fatalf("%s: expected %d nodes, got %d", tc.fen, tc.expected, got)
+		}
+	}
+}
+
+// genFilteredMoves generates legal moves by filtering pseudo-legal moves.  It's
+// used as a reference for the order of moves generated by [GenLegalMoves].
+func genFilteredMoves(p Position, l *MoveList) {
+	l.Len = 0
+
+	genKingMoves(p, l, genAll)
+
+	var pseudoLegal MoveList
+	genPawnMoves(p, &pseudoLegal, ALL_SQUARES, 0, genAll)
+	genNormalMoves(p, &pseudoLegal, ALL_SQUARES, 0, genAll)
+
+	for i := range pseudoLegal.Len {
+		m := pseudoLegal.Moves[i]
+		u := p.MakeMove(m)
+		if GenChecksCounter(p.Bitboards, p.ActiveColor) == 0 {
+			l.Push(m)
+		}
+		p.UnmakeMove(m, u)
+	}
+}
+
+// compareMoveOrder walks the move tree and compares the legal moves with the
+// reference generator in each node.
+func compareMoveOrder(t *testing.T, p *Position, depth int) {
+	var got, expected MoveList
+	GenLegalMoves(*p, &got)
+	genFilteredMoves(*p, &expected)
+
+	if !slices.Equal(got.Moves[:got.Len], expected.Moves[:expected.Len]) {
+		t.Fatalf("%s: expected %v, got %v", SerializeFen(p),
+			expected.Moves[:expected.Len], got.Moves[:got.Len])
+	}
+
+	if depth == 1 {
+		return
+	}
+	for i := range got.Len {
+		m := got.Moves[i]
+		u := p.MakeMove(m)
+		compareMoveOrder(t, p, depth-1)
+		p.UnmakeMove(m, u)
+	}
+}
+
+// TestGenLegalMovesOrder ensures that the order of legal moves matches the order
+// of filtered pseudo-legal moves.
+func TestGenLegalMovesOrder(t *testing.T) {
+	fens := []string{
+		InitialPos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+	}
+
+	for _, fen := range fens {
+		compareMoveOrder(t, ParseFen(fen), 3)
+	}
+}
+
+// compareStagedMoves walks the move tree and ensures that in each node captures
+// and quiet moves are the subsequences of legal moves, which together contain
+// all of them.  Evasions must be the same as legal moves if the king is in check.
+func compareStagedMoves(t *testing.T, p *Position, depth int) {
+	var legal, captures, quiets, evasions MoveList
+	GenLegalMoves(*p, &legal)
+	GenLegalCaptures(*p, &captures)
+	GenLegalQuiets(*p, &quiets)
+	GenLegalEvasions(*p, &evasions)
+
+	fen := SerializeFen(p)
+	if captures.Len+quiets.Len != legal.Len {
+		t.Fatalf("%s: expected %d moves, got %d captures and %d quiets", fen,
+			legal.Len, captures.Len, quiets.Len)
+	}
+
+	var c, q byte
+	for i := range legal.Len {
+		m := legal.Moves[i]
+		isCapture := m.Type() == MovePromotion || m.Type() == MoveEnPassant ||
+			m.Type() != MoveCastling && p.Bitboards[12+(1^p.ActiveColor)]&(1<<m.To()) != 0
+
+		if isCapture && c < captures.Len && captures.Moves[c] == m {
+			c++
+		} else if !isCapture && q < quiets.Len && quiets.Moves[q] == m {
+			q++
+		} else {
+			t.Fatalf("%s: move %s is missing or misplaced", fen, m.UCI())
+		}
+	}
+
+	inCheck := GenChecksCounter(p.Bitboards, 1^p.ActiveColor) > 0
+	if inCheck && !slices.Equal(evasions.Moves[:evasions.Len], legal.Moves[:legal.Len]) ||
+		!inCheck && evasions.Len != 0 {
+		t.Fatalf("%s: unexpected evasions %v", fen, evasions.Moves[:evasions.Len])
+	}
+
+	if depth == 1 {
+		return
+	}
+	for i := range legal.Len {
+		m := legal.Moves[i]
+		u := p.MakeMove(m)
+		compareStagedMoves(t, p, depth-1)
+		p.UnmakeMove(m, u)
+	}
+}
+
+func TestGenStagedMoves(t *testing.T) {
+	fens := []string{
+		InitialPos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	}
+
+	for _, fen := range fens {
+		compareStagedMoves(t, ParseFen(fen), 3)
+	}
+}
+
 func BenchmarkGenPawnAttacks(b *testing.B) {
 	for b.Loop() {
 		genPawnAttacks(B4, ColorWhite)
@@ -68,7 +239,15 @@ func BenchmarkGenKingMoves(b *testing.B) {
 	pos := ParseFen("8/8/8/8/8/8/8/R3K2R w - - 0 1")
 
 	for b.Loop() {
-		genKingMoves(*pos, &MoveList{})
+		genKingMoves(*pos, &MoveList{}, genAll)
+	}
+}
+
+func BenchmarkGenLegalCaptures(b *testing.B) {
+	pos := ParseFen("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+
+	for b.Loop() {
+		GenLegalCaptures(*pos, &MoveList{})
 	}
 }
 
@@ -0,0 +1,156 @@
+// perft.go implements the parallel and hash-assisted perft.
+
+package chego
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// PerftOptions configures [Perft].
+type PerftOptions struct {
+	// Workers is the number of goroutines which search the root moves.  If
+	// zero, [runtime.GOMAXPROCS] goroutines are used.
+	Workers int
+	// HashMB is the size of the transposition table in megabytes, which is
+	// shared between the workers and stores the node counts of the subtrees
+	// by Zobrist key.  Zero disables the table.
+	HashMB int
+}
+
+// Perft walks through the move generation tree of strictly legal moves to the
+// specified depth and returns the number of leaf nodes.  The root moves are
+// split across the workers, and the leaf nodes are counted in bulk.
+//
+// The transposition table relies on the Zobrist keys, so the result may be
+// wrong in the extremely rare case of the key collision.
+//
+// See https://www.chessprogramming.org/Perft
+func Perft(p Position, depth int, opts PerftOptions) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+
+	var l MoveList
+	GenLegalMoves(p, &l)
+	if depth == 1 {
+		return uint64(l.Len)
+	}
+
+	var tt *perftTable
+	if opts.HashMB > 0 {
+		tt = newPerftTable(opts.HashMB)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var next atomic.Int32
+	var nodes atomic.Uint64
+	var wg sync.WaitGroup
+
+	for range min(workers, int(l.Len)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := next.Add(1) - 1
+				if i >= int32(l.Len) {
+					return
+				}
+
+				// Each worker modifies its own copy of the position.
+				pos := p
+				m := l.Moves[i]
+				pos.MakeMove(m)
+				nodes.Add(perftNodes(&pos, depth-1, tt))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nodes.Load()
+}
+
+// perftNodes counts the leaf nodes of the subtree.  tt may be nil.
+func perftNodes(p *Position, depth int, tt *perftTable) uint64 {
+	var l MoveList
+
+	if depth == 1 {
+		GenLegalMoves(*p, &l)
+		return uint64(l.Len)
+	}
+
+	var key uint64
+	if tt != nil {
+		key = p.ZobristKey()
+		if nodes, ok := tt.probe(key, depth); ok {
+			return nodes
+		}
+	}
+
+	GenLegalMoves(*p, &l)
+
+	var nodes uint64
+	for i := range l.Len {
+		m := l.Moves[i]
+		u := p.MakeMove(m)
+		nodes += perftNodes(p, depth-1, tt)
+		p.UnmakeMove(m, u)
+	}
+
+	if tt != nil {
+		tt.store(key, depth, nodes)
+	}
+	return nodes
+}
+
+// perftEntry stores the node count of the subtree.  data packs the depth into
+// the lowest 8 bits and the node count into the rest.  check is the key xored
+// with data, so the torn writes of the concurrent workers are detected without
+// locks.
+type perftEntry struct {
+	check atomic.Uint64
+	data  atomic.Uint64
+}
+
+// perftEntrySize is the size of a single entry in bytes.
+const perftEntrySize = 16
+
+// perftTable is the transposition table of [Perft].  Entries are always
+// replaced.
+type perftTable struct {
+	entries []perftEntry
+	mask    uint64
+}
+
+// newPerftTable creates the table which occupies at most the specified number
+// of megabytes.  The number of entries is rounded down to the power of two.
+func newPerftTable(sizeMB int) *perftTable {
+	n := uint64(1)
+	for n*2*perftEntrySize <= uint64(sizeMB)<<20 {
+		n *= 2
+	}
+	return &perftTable{entries: make([]perftEntry, n), mask: n - 1}
+}
+
+// probe returns the node count of the subtree with the specified key and depth.
+func (t *perftTable) probe(key uint64, depth int) (uint64, bool) {
+	e := &t.entries[key&t.mask]
+	data := e.data.Load()
+	if e.check.Load()^data != key || int(data&0xFF) != depth {
+		return 0, false
+	}
+	return data >> 8, true
+}
+
+// store saves the node count of the subtree with the specified key and depth.
+func (t *perftTable) store(key uint64, depth int, nodes uint64) {
+	data := nodes<<8 | uint64(depth)
+	e := &t.entries[key&t.mask]
+	e.check.Store(key ^ data)
+	e.data.Store(data)
+}
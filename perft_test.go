@@ -0,0 +1,52 @@
+package chego
+
+import "testing"
+
+func TestPerftOptions(t *testing.T) {
+	cases := []struct {
+		fen      string
+		depth    int
+		expected uint64
+	}{
+		{InitialPos, 0, 1},
+		{InitialPos, 1, 20},
+		{InitialPos, 5, 4865609},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 4, 4085603},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5, 674624},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 4, 422333},
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", 4, 326672},
+	}
+
+	options := []PerftOptions{
+		{Workers: 1},
+		{Workers: 4},
+		{Workers: 1, HashMB: 1},
+		{HashMB: 4},
+	}
+
+	for _, tc := range cases {
+		for _, opts := range options {
+			got := Perft(*ParseFen(tc.fen), tc.depth, opts)
+			if got != tc.expected {
+				t.Fatalf("%s %+v: expected %d nodes, got %d", tc.fen, opts,
+					tc.expected, got)
+			}
+		}
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	p := ParseFen(InitialPos)
+
+	for b.Loop() {
+		Perft(*p, 5, PerftOptions{})
+	}
+}
+
+func BenchmarkPerftHash(b *testing.B) {
+	p := ParseFen(InitialPos)
+
+	for b.Loop() {
+		Perft(*p, 5, PerftOptions{HashMB: 16})
+	}
+}
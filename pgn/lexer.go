@@ -0,0 +1,247 @@
+// lexer.go implements splitting of the PGN input into tokens.
+// See http://www.saremba.de/chessgml/standards/pgn/pgn-complete.htm Section 7.
+
+package pgn
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// tokenType is used to distinguish tokens of the PGN input.
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	// '[' opens the tag pair.
+	tokenLBracket
+	// ']' closes the tag pair.
+	tokenRBracket
+	// '(' opens the recursive annotation variation.
+	tokenLParen
+	// ')' closes the recursive annotation variation.
+	tokenRParen
+	// Quoted string with the escape characters removed.
+	tokenString
+	// Tag names, move numbers, SAN moves and game termination markers.
+	tokenSymbol
+	// '.' follows the move number.
+	tokenPeriod
+	// '*' marks the game with the unknown result.
+	tokenAsterisk
+	// '$' followed by the glyph number or the traditional suffix annotation.
+	tokenNAG
+	// Brace or rest of line comment with the delimiters removed.
+	tokenComment
+)
+
+// token represents a single lexical unit of the PGN input.
+type token struct {
+	typ   tokenType
+	value string
+	// Line on which the token starts.  Used to form error messages.
+	line int
+}
+
+// lexer reads tokens from the underlying reader one by one.
+type lexer struct {
+	r    *bufio.Reader
+	line int
+	// Whether the next character is the first one of the line.  Lines which
+	// start with '%' are escaped and must be skipped.
+	lineStart bool
+	// peeked token, if any.
+	peeked *token
+}
+
+func newLexer(r io.Reader) *lexer {
+	return &lexer{r: bufio.NewReader(r), line: 1, lineStart: true}
+}
+
+// peek returns the next token without consuming it.
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.next()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+// read returns and consumes the next token.
+func (l *lexer) read() (token, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		return t, nil
+	}
+	return l.next()
+}
+
+// readByte reads a single byte keeping track of the line number.
+func (l *lexer) readByte() (byte, error) {
+	c, err := l.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if l.lineStart && c == '%' {
+		// Skip the escaped line.
+		if _, err := l.r.ReadString('\n'); err != nil {
+			return 0, err
+		}
+		l.line++
+		return l.readByte()
+	}
+	l.lineStart = c == '\n'
+	if c == '\n' {
+		l.line++
+	}
+	return c, nil
+}
+
+func (l *lexer) unreadByte(c byte) {
+	if err := l.r.UnreadByte(); err != nil {
+		return
+	}
+	if c == '\n' {
+		l.line--
+	}
+}
+
+// next scans the input and returns the next token.  io.EOF is converted into
+// the tokenEOF, other read errors are returned as is.
+func (l *lexer) next() (token, error) {
+	var c byte
+	var err error
+
+	// Skip whitespaces.
+	for {
+		c, err = l.readByte()
+		if err == io.EOF {
+			return token{typ: tokenEOF, line: l.line}, nil
+		} else if err != nil {
+			return token{}, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			break
+		}
+	}
+
+	t := token{line: l.line}
+
+	switch c {
+	case '[':
+		t.typ = tokenLBracket
+	case ']':
+		t.typ = tokenRBracket
+	case '(':
+		t.typ = tokenLParen
+	case ')':
+		t.typ = tokenRParen
+	case '.':
+		t.typ = tokenPeriod
+	case '*':
+		t.typ = tokenAsterisk
+	case '"':
+		t.typ = tokenString
+		t.value, err = l.readString()
+	case '{':
+		t.typ = tokenComment
+		t.value, err = l.readUntil('}')
+	case ';':
+		t.typ = tokenComment
+		t.value, err = l.readUntil('\n')
+	case '$':
+		t.typ = tokenNAG
+		t.value, err = l.readWhile(isDigit)
+	case '!', '?':
+		t.typ = tokenNAG
+		l.unreadByte(c)
+		t.value, err = l.readWhile(func(c byte) bool { return c == '!' || c == '?' })
+	default:
+		if !isSymbolStart(c) {
+			return t, &SyntaxError{Line: t.line, Msg: "unexpected character " +
+				string(c)}
+		}
+		t.typ = tokenSymbol
+		l.unreadByte(c)
+		t.value, err = l.readWhile(isSymbolContinuation)
+	}
+
+	if err == io.EOF && t.typ != tokenString && t.typ != tokenComment {
+		err = nil
+	} else if err == io.EOF {
+		return t, &SyntaxError{Line: t.line, Msg: "unterminated string or comment"}
+	}
+	return t, err
+}
+
+// readString reads the quoted string and resolves the "\\" and "\"" escape
+// sequences.  The opening quote must be already consumed.
+func (l *lexer) readString() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return b.String(), err
+		}
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			c, err = l.readByte()
+			if err != nil {
+				return b.String(), err
+			}
+		}
+		b.WriteByte(c)
+	}
+}
+
+// readUntil reads the input until the delimiter is reached.  The delimiter is
+// consumed but not included into the result.  Reaching the end of the input
+// before the new line is not considered an error.
+func (l *lexer) readUntil(delim byte) (string, error) {
+	var b strings.Builder
+	for {
+		c, err := l.readByte()
+		if err == io.EOF && delim == '\n' {
+			return b.String(), nil
+		} else if err != nil {
+			return b.String(), err
+		}
+		if c == delim {
+			return strings.TrimSpace(b.String()), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+// readWhile reads the input while the characters satisfy the predicate.
+func (l *lexer) readWhile(pred func(byte) bool) (string, error) {
+	var b strings.Builder
+	for {
+		c, err := l.readByte()
+		if err != nil {
+			return b.String(), err
+		}
+		if !pred(c) {
+			l.unreadByte(c)
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isSymbolStart(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSymbolContinuation(c byte) bool {
+	return isSymbolStart(c) || strings.IndexByte("_+#=:-/", c) != -1
+}
@@ -0,0 +1,146 @@
+// Package pgn implements parsing and serialization of chess games stored in the
+// Portable Game Notation.
+//
+// See http://www.saremba.de/chessgml/standards/pgn/pgn-complete.htm.
+package pgn
+
+import (
+	"errors"
+
+	"github.com/treepeck/chego"
+)
+
+// Game termination markers.
+const (
+	ResultWhiteWins = "1-0"
+	ResultBlackWins = "0-1"
+	ResultDraw      = "1/2-1/2"
+	ResultUnknown   = "*"
+)
+
+// Tag represents a single PGN tag pair, e.g. [Event "Casual game"].
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// Node represents a single move within the game tree.
+type Node struct {
+	// Parent is the node which precedes the move.  nil for the root node.
+	Parent *Node
+	// Variations stores the continuations of the node.  The first element is
+	// the main line, the rest are alternatives to it.
+	Variations []*Node
+	// Move which leads to this node.  Zero for the root node.
+	Move chego.Move
+	// SAN is the Standard Algebraic Notation of the Move, including the check
+	// and checkmate indicators.
+	SAN string
+	// Position after the Move is played.  For the root node it is the starting
+	// position of the game.
+	Position chego.Position
+	// NAGs stores the Numeric Annotation Glyphs attached to the move.
+	NAGs []int
+	// PreComments stores the comments placed right before the first move of a
+	// variation.
+	PreComments []string
+	// Comments stores the comments placed after the move.  For the root node
+	// these are the comments placed before the first move of the game.
+	Comments []string
+}
+
+// Game represents a single PGN game.
+type Game struct {
+	// Tags are stored in the order in which they have been read or set.
+	Tags []Tag
+	// Root holds the starting position of the game.
+	Root *Node
+	// Result is the game termination marker.
+	Result string
+}
+
+// ErrInvalidFEN is returned when the FEN tag cannot be parsed.
+var ErrInvalidFEN = errors.New("invalid FEN tag")
+
+// NewGame creates a new game which starts from the specified position.
+func NewGame(start chego.Position) *Game {
+	return &Game{
+		Root:   &Node{Position: start},
+		Result: ResultUnknown,
+	}
+}
+
+// Tag returns the value of the tag with the specified name, or an empty string
+// if the game doesn't contain such a tag.
+func (g *Game) Tag(name string) string {
+	for _, t := range g.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// SetTag updates the value of the tag with the specified name.  The tag is
+// appended if the game doesn't contain it yet.
+func (g *Game) SetTag(name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{Name: name, Value: value})
+}
+
+// MainLine returns the nodes of the main line, excluding the root.
+func (g *Game) MainLine() []*Node {
+	line := make([]*Node, 0)
+	for n := g.Root; len(n.Variations) > 0; {
+		n = n.Variations[0]
+		line = append(line, n)
+	}
+	return line
+}
+
+// End returns the last node of the main line.
+func (g *Game) End() *Node {
+	n := g.Root
+	for len(n.Variations) > 0 {
+		n = n.Variations[0]
+	}
+	return n
+}
+
+// AddMove appends the specified move as a new continuation of the node.  The
+// first added continuation becomes the main line, the following ones become
+// variations.  Returns [chego.ErrIllegalMove] if the move is not legal in the
+// node's position.
+func (n *Node) AddMove(m chego.Move) (*Node, error) {
+	var legal chego.MoveList
+	chego.GenLegalMoves(n.Position, &legal)
+
+	isLegal := false
+	for i := range legal.Len {
+		if legal.Moves[i] == m {
+			isLegal = true
+			break
+		}
+	}
+	if !isLegal {
+		return nil, chego.ErrIllegalMove
+	}
+
+	child := &Node{Parent: n, Move: m, Position: n.Position}
+	child.SAN = chego.Move2SAN(m, &child.Position, &legal)
+	n.Variations = append(n.Variations, child)
+	return child, nil
+}
+
+// Ply returns the number of half-moves played from the root to the node.
+func (n *Node) Ply() (ply int) {
+	for ; n.Parent != nil; n = n.Parent {
+		ply++
+	}
+	return ply
+}
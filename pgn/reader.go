@@ -0,0 +1,239 @@
+// reader.go implements parsing of PGN games in the import format.
+
+package pgn
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+// SyntaxError is returned when the PGN input cannot be parsed.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("pgn: line %d: %s", e.Line, e.Msg)
+}
+
+// MoveError is returned when the SAN token cannot be applied to the position.
+type MoveError struct {
+	Line int
+	SAN  string
+	Err  error
+}
+
+func (e *MoveError) Error() string {
+	return fmt.Sprintf("pgn: line %d: move %s: %v", e.Line, e.SAN, e.Err)
+}
+
+func (e *MoveError) Unwrap() error { return e.Err }
+
+// Traditional suffix annotations and the NAGs they are equivalent to.
+var suffixNAGs = map[string]int{
+	"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6,
+}
+
+// Reader reads consecutive games from the PGN database.
+type Reader struct {
+	l *lexer
+}
+
+// NewReader creates a new reader which reads games from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{l: newLexer(r)}
+}
+
+// Parse parses a single game from the specified string.
+func Parse(s string) (*Game, error) {
+	return NewReader(strings.NewReader(s)).Read()
+}
+
+// Read reads the next game.  Returns io.EOF when there are no more games.
+//
+// Each SAN token is replayed against the current position, so the resulting
+// game tree holds only legal moves.  Tags are not validated, except for the
+// "FEN" tag which defines the starting position.
+func (r *Reader) Read() (*Game, error) {
+	t, err := r.l.peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.typ == tokenEOF {
+		return nil, io.EOF
+	}
+
+	g := &Game{Result: ResultUnknown}
+
+	if err = r.readTags(g); err != nil {
+		return nil, err
+	}
+
+	start := chego.ParseFen(chego.InitialPos)
+	if fen := g.Tag("FEN"); fen != "" {
+		start, err = chego.ParseFENStrict(fen)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFEN, err)
+		}
+	}
+	g.Root = &Node{Position: *start}
+
+	isEnd, err := r.readLine(g, g.Root, false)
+	if err != nil {
+		return nil, err
+	}
+	// The termination marker is missing in the movetext.
+	if !isEnd {
+		if res := g.Tag("Result"); res != "" {
+			g.Result = res
+		}
+	}
+	return g, nil
+}
+
+// readTags reads the tag pair section of the game.
+func (r *Reader) readTags(g *Game) error {
+	for {
+		t, err := r.l.peek()
+		if err != nil {
+			return err
+		}
+		if t.typ != tokenLBracket {
+			return nil
+		}
+		r.l.read()
+
+		name, err := r.expect(tokenSymbol)
+		if err != nil {
+			return err
+		}
+		value, err := r.expect(tokenString)
+		if err != nil {
+			return err
+		}
+		if _, err = r.expect(tokenRBracket); err != nil {
+			return err
+		}
+		g.Tags = append(g.Tags, Tag{Name: name.value, Value: value.value})
+	}
+}
+
+// expect reads the next token and ensures it has the specified type.
+func (r *Reader) expect(typ tokenType) (token, error) {
+	t, err := r.l.read()
+	if err != nil {
+		return t, err
+	}
+	if t.typ != typ {
+		return t, &SyntaxError{Line: t.line, Msg: "unexpected token " +
+			strconv.Quote(t.value)}
+	}
+	return t, nil
+}
+
+// readLine reads the sequence of moves starting at the specified node until
+// the end of the variation or the game.  Returns true if the game termination
+// marker has been read.
+func (r *Reader) readLine(g *Game, start *Node, isVariation bool) (bool, error) {
+	last := start
+	// Comments which precede the first move of the variation.
+	var pending []string
+
+	for {
+		t, err := r.l.read()
+		if err != nil {
+			return false, err
+		}
+
+		switch t.typ {
+		case tokenEOF:
+			if isVariation {
+				return false, &SyntaxError{Line: t.line, Msg: "unterminated variation"}
+			}
+			return false, nil
+
+		case tokenLBracket:
+			// The next game starts, but the termination marker is missing.
+			if isVariation {
+				return false, &SyntaxError{Line: t.line, Msg: "unterminated variation"}
+			}
+			r.l.peeked = &t
+			return false, nil
+
+		case tokenPeriod:
+			// Move number indications are ignored.
+
+		case tokenAsterisk:
+			if isVariation {
+				return false, &SyntaxError{Line: t.line, Msg: "unexpected result"}
+			}
+			g.Result = ResultUnknown
+			return true, nil
+
+		case tokenComment:
+			if last == start && isVariation {
+				pending = append(pending, t.value)
+			} else {
+				last.Comments = append(last.Comments, t.value)
+			}
+
+		case tokenNAG:
+			nag, ok := suffixNAGs[t.value]
+			if !ok {
+				nag, err = strconv.Atoi(t.value)
+				if err != nil || nag > 255 {
+					return false, &SyntaxError{Line: t.line, Msg: "invalid NAG " + t.value}
+				}
+			}
+			last.NAGs = append(last.NAGs, nag)
+
+		case tokenLParen:
+			if last == start {
+				return false, &SyntaxError{Line: t.line, Msg: "variation without a move"}
+			}
+			// The variation is an alternative to the last move.
+			if _, err = r.readLine(g, last.Parent, true); err != nil {
+				return false, err
+			}
+
+		case tokenRParen:
+			if !isVariation {
+				return false, &SyntaxError{Line: t.line, Msg: "unexpected ')'"}
+			}
+			return false, nil
+
+		case tokenSymbol:
+			switch t.value {
+			case ResultWhiteWins, ResultBlackWins, ResultDraw:
+				if isVariation {
+					return false, &SyntaxError{Line: t.line, Msg: "unexpected result"}
+				}
+				g.Result = t.value
+				return true, nil
+			}
+
+			// Skip move numbers.
+			if _, err := strconv.Atoi(t.value); err == nil {
+				continue
+			}
+
+			m, err := chego.ParseSAN(t.value, &last.Position)
+			if err != nil {
+				return false, &MoveError{Line: t.line, SAN: t.value, Err: err}
+			}
+			node, _ := last.AddMove(m)
+			node.PreComments = pending
+			pending = nil
+			last = node
+
+		default:
+			return false, &SyntaxError{Line: t.line, Msg: "unexpected token " +
+				strconv.Quote(t.value)}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package pgn
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestParse(t *testing.T) {
+	input := `[Event "Casual game"]
+[White "Alice"]
+[Black "Bob"]
+[Result "1-0"]
+
+{Opening comment} 1. e4 e5 {Nf6 would be a mistake here} 2. Nf3 $1 (2. Bc4!? Nc6
+(2... Nf6 3. d3) 3. Qh5) 2... Nc6 3. Bc4 Nf6?? 4. Ng5 ; rest of line comment
+d5 5. exd5 Na5 6. Bb5+ c6 7. dxc6 bxc6 8. Qf3 cxb5 9. Qxa8 1-0
+`
+
+	g, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Tag("White") != "Alice" || g.Tag("Event") != "Casual game" {
+		t.Fatalf("unexpected tags: %v", g.Tags)
+	}
+	if g.Result != ResultWhiteWins {
+		t.Fatalf("expected result 1-0, got %s", g.Result)
+	}
+	if len(g.Root.Comments) != 1 || g.Root.Comments[0] != "Opening comment" {
+		t.Fatalf("unexpected root comments: %v", g.Root.Comments)
+	}
+
+	line := g.MainLine()
+	if len(line) != 17 {
+		t.Fatalf("expected 17 plies in the main line, got %d", len(line))
+	}
+	// Nf6 inside the comment must not be treated as a move.
+	if line[1].SAN != "e5" || line[1].Comments[0] != "Nf6 would be a mistake here" {
+		t.Fatalf("unexpected node: %s %v", line[1].SAN, line[1].Comments)
+	}
+	if len(line[2].NAGs) != 1 || line[2].NAGs[0] != 1 {
+		t.Fatalf("expected NAG $1, got %v", line[2].NAGs)
+	}
+	if len(line[5].NAGs) != 1 || line[5].NAGs[0] != 4 {
+		t.Fatalf("expected ?? to be converted into $4, got %v", line[5].NAGs)
+	}
+	if line[6].Comments[0] != "rest of line comment" {
+		t.Fatalf("unexpected comment: %v", line[6].Comments)
+	}
+
+	// 2. Bc4 is the alternative to 2. Nf3.
+	alts := line[1].Variations
+	if len(alts) != 2 || alts[1].SAN != "Bc4" || alts[1].NAGs[0] != 5 {
+		t.Fatalf("unexpected variation: %v", alts)
+	}
+	// 2... Nf6 is the alternative to 2... Nc6 inside the variation.
+	nested := alts[1].Variations
+	if len(nested) != 2 || nested[1].SAN != "Nf6" ||
+		nested[1].Variations[0].SAN != "d3" {
+		t.Fatalf("unexpected nested variation: %v", nested)
+	}
+
+	last := line[len(line)-1]
+	if last.SAN != "Qxa8" || last.Move != chego.NewMove(chego.SA8, chego.SF3, chego.MoveNormal) {
+		t.Fatalf("unexpected last move: %s", last.SAN)
+	}
+}
+
+func TestParseFENTag(t *testing.T) {
+	g, err := Parse(`[FEN "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"]
+[SetUp "1"]
+
+1. e4 Kd7 *`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := chego.SerializeFen(&g.End().Position)
+	if got != "8/3k4/8/8/4P3/8/8/4K3 w - - 1 2" {
+		t.Fatalf("unexpected position: %s", got)
+	}
+}
+
+func TestReadMultipleGames(t *testing.T) {
+	r := NewReader(strings.NewReader(`[Event "First"]
+
+1. d4 d5 1/2-1/2
+
+[Event "Second"]
+
+1. e4 0-1
+`))
+
+	var events []string
+	for {
+		g, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, g.Tag("Event")+" "+g.Result)
+	}
+
+	if len(events) != 2 || events[0] != "First 1/2-1/2" || events[1] != "Second 0-1" {
+		t.Fatalf("unexpected games: %v", events)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input     string
+		isMoveErr bool
+	}{
+		{"1. e4 e5 2. Ke3 *", true},
+		{"1. e4 (1. d4 *", false},
+		{"1. e4 ) *", false},
+		{"[Event \"Unterminated]", false},
+		{"(1. e4) *", false},
+	}
+
+	if _, err := Parse("[FEN \"8/8/8/8/8/8/8/8 w - - 0 1\"]\n\n*"); !errors.Is(err, ErrInvalidFEN) {
+		t.Fatalf("expected ErrInvalidFEN, got %v", err)
+	}
+
+	for _, tc := range cases {
+		_, err := Parse(tc.input)
+
+		var moveErr *MoveError
+		var syntaxErr *SyntaxError
+		if tc.isMoveErr && !errors.As(err, &moveErr) {
+			t.Fatalf("%q: expected move error, got %v", tc.input, err)
+		}
+		if !tc.isMoveErr && !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected syntax error, got %v", tc.input, err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	input := `1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6
+8. c3 O-O 9. h3 Nb8 10. d4 Nbd7 *`
+
+	for b.Loop() {
+		Parse(input)
+	}
+}
@@ -0,0 +1,203 @@
+// writer.go implements serialization of PGN games in the export format.
+// See http://www.saremba.de/chessgml/standards/pgn/pgn-complete.htm Section 8.
+
+package pgn
+
+import (
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+// maxLineLen is the maximum length of the movetext line in the export format.
+const maxLineLen = 79
+
+// sevenTagRoster lists the mandatory tags in the order they must be exported,
+// along with their default values.
+var sevenTagRoster = [7]Tag{
+	{"Event", "?"},
+	{"Site", "?"},
+	{"Date", "????.??.??"},
+	{"Round", "?"},
+	{"White", "?"},
+	{"Black", "?"},
+	{"Result", ResultUnknown},
+}
+
+// Write writes the game into w in the PGN export format.
+func Write(w io.Writer, g *Game) error {
+	_, err := io.WriteString(w, g.String())
+	return err
+}
+
+// String returns the game in the PGN export format.
+//
+// The Seven Tag Roster is written first, followed by the rest of the tags in
+// ASCII order.  If the game doesn't start from the initial position, the
+// "SetUp" and "FEN" tags are written from the root position, replacing the
+// stored ones.  Movetext lines are wrapped to be no longer than 79 characters.
+func (g *Game) String() string {
+	var b strings.Builder
+
+	writeTags(&b, g)
+	b.WriteByte('\n')
+
+	var mw movetextWriter
+	for _, c := range g.Root.Comments {
+		mw.writeComment(c)
+	}
+	mw.writeLine(g.Root, true)
+	mw.writeToken(g.Result)
+	mw.flush(&b)
+	b.WriteString("\n\n")
+
+	return b.String()
+}
+
+func writeTags(b *strings.Builder, g *Game) {
+	for _, t := range sevenTagRoster {
+		value := g.Tag(t.Name)
+		if t.Name == "Result" {
+			value = g.Result
+		}
+		if value == "" {
+			value = t.Value
+		}
+		writeTag(b, t.Name, value)
+	}
+
+	fen := chego.SerializeFen(&g.Root.Position)
+	isSetUp := fen != chego.InitialPos
+
+	rest := make([]Tag, 0, len(g.Tags)+2)
+	if isSetUp {
+		rest = append(rest, Tag{"SetUp", "1"}, Tag{"FEN", fen})
+	}
+	for _, t := range g.Tags {
+		if isSetUp && (t.Name == "SetUp" || t.Name == "FEN") {
+			continue
+		}
+
+		isRoster := false
+		for _, r := range sevenTagRoster {
+			if r.Name == t.Name {
+				isRoster = true
+				break
+			}
+		}
+		if !isRoster {
+			rest = append(rest, t)
+		}
+	}
+	slices.SortStableFunc(rest, func(a, b Tag) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, t := range rest {
+		writeTag(b, t.Name, t.Value)
+	}
+}
+
+func writeTag(b *strings.Builder, name, value string) {
+	b.WriteByte('[')
+	b.WriteString(name)
+	b.WriteString(" \"")
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	b.WriteString(strings.ReplaceAll(value, "\"", "\\\""))
+	b.WriteString("\"]\n")
+}
+
+// movetextWriter collects the movetext tokens which are later wrapped into
+// lines.
+type movetextWriter struct {
+	tokens []string
+	// Prefix of the next token.  Used to glue the opening parenthesis to the
+	// first move of the variation.
+	prefix string
+}
+
+func (w *movetextWriter) writeToken(tok string) {
+	w.tokens = append(w.tokens, w.prefix+tok)
+	w.prefix = ""
+}
+
+// flush writes the collected tokens into b, separating them with a single
+// space and wrapping the lines which exceed the [maxLineLen].
+func (w *movetextWriter) flush(b *strings.Builder) {
+	lineLen := 0
+	for _, tok := range w.tokens {
+		if lineLen > 0 && lineLen+1+len(tok) > maxLineLen {
+			b.WriteByte('\n')
+			lineLen = 0
+		} else if lineLen > 0 {
+			b.WriteByte(' ')
+			lineLen++
+		}
+		b.WriteString(tok)
+		lineLen += len(tok)
+	}
+	w.tokens = w.tokens[:0]
+}
+
+// writeComment writes the brace comment splitting it into words, so the
+// comment can be wrapped.  Closing braces are not allowed inside the comment
+// and are removed.
+func (w *movetextWriter) writeComment(c string) {
+	words := strings.Fields(strings.ReplaceAll(c, "}", ""))
+	if len(words) == 0 {
+		w.writeToken("{}")
+		return
+	}
+	words[0] = "{" + words[0]
+	words[len(words)-1] += "}"
+	for _, word := range words {
+		w.writeToken(word)
+	}
+}
+
+// writeMove writes the move of the node along with its move number, NAGs and
+// comments.  Black move numbers are written only when forced.
+func (w *movetextWriter) writeMove(n *Node, forceNumber bool) {
+	for _, c := range n.PreComments {
+		w.writeComment(c)
+	}
+
+	before := n.Parent.Position
+	if before.ActiveColor == chego.ColorWhite {
+		w.writeToken(strconv.Itoa(before.FullmoveCnt) + ".")
+	} else if forceNumber || len(n.PreComments) > 0 {
+		w.writeToken(strconv.Itoa(before.FullmoveCnt) + "...")
+	}
+
+	w.writeToken(n.SAN)
+
+	for _, nag := range n.NAGs {
+		w.writeToken("$" + strconv.Itoa(nag))
+	}
+	for _, c := range n.Comments {
+		w.writeComment(c)
+	}
+}
+
+// writeLine writes the main line which continues the specified node along with
+// all its variations.
+func (w *movetextWriter) writeLine(n *Node, forceNumber bool) {
+	for len(n.Variations) > 0 {
+		main := n.Variations[0]
+		w.writeMove(main, forceNumber)
+		forceNumber = len(main.Comments) > 0
+
+		for _, alt := range n.Variations[1:] {
+			w.prefix = "("
+			w.writeMove(alt, true)
+			w.writeLine(alt, len(alt.Comments) > 0)
+			w.tokens[len(w.tokens)-1] += ")"
+			forceNumber = true
+		}
+
+		n = main
+	}
+}
@@ -0,0 +1,112 @@
+package pgn
+
+import (
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+func TestString(t *testing.T) {
+	input := `[White "Alice"]
+[ECO "C42"]
+[Black "Bob"]
+[Annotator "Carol"]
+
+{Opening comment} 1. e4 e5 {Nf6 would be a mistake here} 2. Nf3 !
+(2. Bc4 Nc6 (2... Nf6) 3. Qh5) Nc6 3. Bc4 Nf6 4. Ng5 d5 5. exd5 Na5 6. Bb5+ c6
+7. dxc6 bxc6 8. Qf3 cxb5 9. Qxa8 1-0`
+
+	expected := `[Event "?"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "Alice"]
+[Black "Bob"]
+[Result "1-0"]
+[Annotator "Carol"]
+[ECO "C42"]
+
+{Opening comment} 1. e4 e5 {Nf6 would be a mistake here} 2. Nf3 $1 (2. Bc4 Nc6
+(2... Nf6) 3. Qh5) 2... Nc6 3. Bc4 Nf6 4. Ng5 d5 5. exd5 Na5 6. Bb5+ c6 7. dxc6
+bxc6 8. Qf3 cxb5 9. Qxa8 1-0
+
+`
+
+	g, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := g.String()
+	if got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// The exported game must be parsed into the same game.
+	again, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != got {
+		t.Fatalf("round trip failed:\n%s", again.String())
+	}
+}
+
+func TestNewGame(t *testing.T) {
+	g := NewGame(*chego.ParseFen("4k3/8/8/8/8/8/8/R3K3 b Q - 0 30"))
+	g.SetTag("Event", "Endgame")
+
+	n, err := g.Root.AddMove(chego.NewMove(chego.SD8, chego.SE8, chego.MoveNormal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = n.AddMove(chego.NewMove(chego.SC1, chego.SE1, chego.MoveCastling)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = n.AddMove(chego.NewMove(chego.SA8, chego.SA1, chego.MoveNormal)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = n.AddMove(chego.NewMove(chego.SA2, chego.SB1, chego.MoveNormal)); err != chego.ErrIllegalMove {
+		t.Fatalf("expected ErrIllegalMove, got %v", err)
+	}
+	g.Result = ResultDraw
+
+	expected := `[Event "Endgame"]
+[Site "?"]
+[Date "????.??.??"]
+[Round "?"]
+[White "?"]
+[Black "?"]
+[Result "1/2-1/2"]
+[FEN "4k3/8/8/8/8/8/8/R3K3 b Q - 0 30"]
+[SetUp "1"]
+
+30... Kd8 31. O-O-O+ (31. Ra8+) 1/2-1/2
+
+`
+	got := g.String()
+	if got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// The starting position must be restored from the FEN tag.
+	again, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.String() != got {
+		t.Fatalf("round trip failed:\n%s", again.String())
+	}
+}
+
+func BenchmarkString(b *testing.B) {
+	g, err := Parse(`1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5
+7. Bb3 d6 8. c3 O-O 9. h3 Nb8 10. d4 Nbd7 *`)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		_ = g.String()
+	}
+}
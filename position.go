@@ -1,20 +1,21 @@
 package chego
 
+import "fmt"
+
 var (
 	// Each piece weight used to calculate material on the board.
-	// Use Piece type as index to get it's weight.
-	pieceWeights = [10]int{1, 1, 3, 3, 3, 3, 5, 5, 9, 9}
-	// Each path includes the king square.
-	// 0 : White O-O castling path.
-	// 1 : White O-O-O castling path.
-	// 2 : Black O-O castling path.
-	// 3 : Black O-O-O castling path.
-	castlingPath = [4]uint64{
-		0x70, 0x1E, 0x7000000000000000, 0x1E00000000000000,
-	}
-	castlingAttackPath = [4]uint64{
-		0x70, 0x1C, 0x7000000000000000, 0x1C00000000000000,
-	}
+	// Use Piece type as index to get it's weight.  Also used as the default
+	// weights for [SEE].
+	pieceWeights = PieceWeights{1, 1, 3, 3, 3, 3, 5, 5, 9, 9}
+	// Destination squares of the king and the rook for each castling right in
+	// the order of [CastlingRights] bits.  They are the same in Chess960.
+	castlingKingDest = [4]int{SG1, SC1, SG8, SC8}
+	castlingRookDest = [4]int{SF1, SD1, SF8, SD8}
+	// Initial squares of the castling rooks in the standard chess.
+	standardCastlingRooks = [4]int{SH1, SA1, SH8, SA8}
+	// Castling rights which are lost when a piece moves from or to each square
+	// in the standard chess.
+	standardCastlingMasks = initCastlingMasks()
 )
 
 // Position represents a chessboard state that can be converted to or parsed from
@@ -26,6 +27,36 @@ type Position struct {
 	EPTarget       int
 	HalfmoveCnt    int
 	FullmoveCnt    int
+	// Chess960 enables the Fischer Random Chess castling rules.  Castling moves
+	// are encoded as the king capturing its own rook, e.g. e1h1.  Otherwise,
+	// castling moves are encoded with the king's destination square, e.g. e1g1.
+	Chess960 bool
+	// CastlingRooks stores the initial rook square for each castling right in
+	// the order of [CastlingRights] bits.  Only used if Chess960 is enabled,
+	// since the rooks always start on the corners in the standard chess.
+	// [ParseFen] fills it for all positions, so Chess960 can be enabled after
+	// parsing, e.g. for the standard initial position.
+	CastlingRooks [4]int
+	// mailbox stores the piece which stands on each square plus one, so the
+	// zero value means the empty square.  It is kept in sync with the
+	// bitboards by placePiece and removePiece.
+	mailbox [64]uint8
+	// key is the Zobrist key of the piece placement, castling rights and the
+	// active color.  It is set by [ParseFen] and updated incrementally by
+	// [Position.MakeMove] and [Position.UnmakeMove].
+	key uint64
+}
+
+// Undo stores the parts of the position which cannot be restored from the move
+// itself.  It is returned by [Position.MakeMove] and used by
+// [Position.UnmakeMove] to take the move back.
+type Undo struct {
+	Moved          Piece
+	Captured       Piece
+	CastlingRights CastlingRights
+	EPTarget       int
+	HalfmoveCnt    int
+	key            uint64
 }
 
 // MakeMove modifies the position by applying the specified move.  It is the
@@ -34,8 +65,29 @@ type Position struct {
 //
 // Not only is the piece placement updated, but also the entire position, including
 // castling rights, en passant target, halfmove counter, fullmove counter, and the
-// active color.
-func (p *Position) MakeMove(m Move, moved, captured Piece) {
+// active color.  The returned [Undo] can be passed to [Position.UnmakeMove] to
+// restore the position.
+func (p *Position) MakeMove(m Move) Undo {
+	moved := p.pieceOn(m.From())
+	captured := p.pieceOn(m.To())
+	// Moving the king or the rook from its initial square, as well as capturing
+	// the rook on it, disables the castling rights.  The mask is computed
+	// before the king leaves its square.
+	mask := p.castlingMask(m.From()) | p.castlingMask(m.To())
+	// The king captures its own rook in Chess960 castling.
+	if m.Type() == MoveCastling {
+		captured = PieceNone
+	}
+
+	u := Undo{
+		Moved:          moved,
+		Captured:       captured,
+		CastlingRights: p.CastlingRights,
+		EPTarget:       p.EPTarget,
+		HalfmoveCnt:    p.HalfmoveCnt,
+		key:            p.key,
+	}
+
 	to := uint64(1 << m.To())
 	from := uint64(1 << m.From())
 
@@ -69,22 +121,12 @@ func (p *Position) MakeMove(m Move, moved, captured Piece) {
 		}
 
 	case MoveCastling:
-		p.placePiece(moved, to)
-		// Update the rook position.
-		switch to {
-		case G1: // White O-O.
-			p.removePiece(WRook, H1)
-			p.placePiece(WRook, F1)
-		case G8: // Black O-O.
-			p.removePiece(BRook, H8)
-			p.placePiece(BRook, F8)
-		case C1: // White O-O-O.
-			p.removePiece(WRook, A1)
-			p.placePiece(WRook, D1)
-		case C8: // Black O-O-O.
-			p.removePiece(BRook, A8)
-			p.placePiece(BRook, D8)
-		}
+		// Remove the rook first, since in Chess960 the king may land on its
+		// square.
+		i := p.castlingIndex(m)
+		p.removePiece(WRook+p.ActiveColor, 1<<p.castlingRook(i))
+		p.placePiece(moved, 1<<castlingKingDest[i])
+		p.placePiece(WRook+p.ActiveColor, 1<<castlingRookDest[i])
 
 	case MovePromotion:
 		switch m.PromoPiece() {
@@ -113,30 +155,10 @@ func (p *Position) MakeMove(m Move, moved, captured Piece) {
 		}
 		// Reset the halfmove counter after pawn moves.
 		p.HalfmoveCnt = 0
-	// The king cannot castle with a rook that has already moved.
-	case WRook:
-		switch m.From() {
-		case SA1:
-			p.CastlingRights &= ^CastlingWhiteLong
-		case SH1:
-			p.CastlingRights &= ^CastlingWhiteShort
-		}
-	// The king cannot castle with a rook that has already moved.
-	case BRook:
-		switch m.From() {
-		case SA8:
-			p.CastlingRights &= ^CastlingBlackLong
-		case SH8:
-			p.CastlingRights &= ^CastlingBlackShort
-		}
-	// Disable white castling rights.
-	case WKing:
-		p.CastlingRights &= ^(CastlingWhiteShort | CastlingWhiteLong)
-	// Disable black castling rights.
-	case BKing:
-		p.CastlingRights &= ^(CastlingBlackShort | CastlingBlackLong)
 	}
 
+	p.CastlingRights &^= mask
+
 	// Increment the full move counter after black moves.
 	if p.ActiveColor == ColorBlack {
 		p.FullmoveCnt++
@@ -144,6 +166,80 @@ func (p *Position) MakeMove(m Move, moved, captured Piece) {
 
 	// Switch the active color.
 	p.ActiveColor ^= 1
+
+	p.key ^= castlingKeys[u.CastlingRights] ^ castlingKeys[p.CastlingRights] ^
+		colorKey
+
+	if debug {
+		p.checkConsistency()
+	}
+
+	return u
+}
+
+// UnmakeMove takes back the specified move, which must be the last move made by
+// [Position.MakeMove] that returned u.
+func (p *Position) UnmakeMove(m Move, u Undo) {
+	// Switch the active color back.
+	p.ActiveColor ^= 1
+
+	// Decrement the full move counter if black has made the move.
+	p.FullmoveCnt -= p.ActiveColor
+
+	to := uint64(1 << m.To())
+	from := uint64(1 << m.From())
+
+	switch m.Type() {
+	case MoveNormal:
+		// Move the piece back in a single step.
+		p.Bitboards[u.Moved] ^= from | to
+		p.Bitboards[12+p.ActiveColor] ^= from | to
+		p.Bitboards[14] ^= from
+		p.mailbox[m.From()] = uint8(u.Moved + 1)
+		p.mailbox[m.To()] = 0
+
+	case MovePromotion:
+		// Replace the promoted piece with the pawn.
+		p.removePiece(WKnight+2*m.PromoPiece()+p.ActiveColor, to)
+		p.placePiece(u.Moved, from)
+
+	case MoveEnPassant:
+		p.removePiece(u.Moved, to)
+		p.placePiece(u.Moved, from)
+		// Restore the captured pawn.
+		if u.Moved == WPawn {
+			p.placePiece(BPawn, to>>8)
+		} else {
+			p.placePiece(WPawn, to<<8)
+		}
+
+	case MoveCastling:
+		// Clear both destination squares before restoring the pieces, since
+		// in Chess960 they may overlap with the initial squares.
+		i := p.castlingIndex(m)
+		p.removePiece(u.Moved, 1<<castlingKingDest[i])
+		p.removePiece(WRook+p.ActiveColor, 1<<castlingRookDest[i])
+		p.placePiece(u.Moved, from)
+		p.placePiece(WRook+p.ActiveColor, 1<<p.castlingRook(i))
+	}
+
+	if u.Captured != PieceNone {
+		p.placePiece(u.Captured, to)
+	} else if m.Type() == MoveNormal {
+		// The destination square is empty after the quiet move.
+		p.Bitboards[14] ^= to
+	}
+
+	p.CastlingRights = u.CastlingRights
+	p.EPTarget = u.EPTarget
+	p.HalfmoveCnt = u.HalfmoveCnt
+	// The key is restored rather than updated, since the quiet moves are taken
+	// back without calling placePiece and removePiece.
+	p.key = u.key
+
+	if debug {
+		p.checkConsistency()
+	}
 }
 
 // IsInsufficientMaterial returns true if one of the following statements is true:
@@ -177,32 +273,65 @@ func (p *Position) IsInsufficientMaterial() bool {
 // GetPieceFromSquare returns the type of the piece that stands on the specified
 // square, or [PieceNone] if the square is empty.
 func (p *Position) GetPieceFromSquare(square uint64) Piece {
-	for i := range p.Bitboards {
-		if square&p.Bitboards[i] != 0 {
-			return i
+	if square == 0 {
+		return PieceNone
+	}
+	return p.pieceOn(bitScan(square))
+}
+
+// pieceOn returns the piece which stands on the square with the specified
+// index, or [PieceNone] if the square is empty.
+func (p *Position) pieceOn(sq int) Piece {
+	return Piece(p.mailbox[sq]) - 1
+}
+
+// castlingRook returns the initial square of the rook for the castling right
+// with the specified index in the order of [CastlingRights] bits.
+func (p *Position) castlingRook(i int) int {
+	if p.Chess960 {
+		return p.CastlingRooks[i]
+	}
+	return standardCastlingRooks[i]
+}
+
+// castlingMask returns the castling rights which are lost when a piece moves
+// from or to the specified square.  In Chess960 the initial squares of the kings
+// are not fixed, but a king which has any castling right has not moved yet.
+func (p *Position) castlingMask(square int) (mask CastlingRights) {
+	if !p.Chess960 {
+		return standardCastlingMasks[square]
+	}
+	for i := range p.CastlingRooks {
+		if p.CastlingRooks[i] == square ||
+			p.Bitboards[WKing+i/2]&(1<<square) != 0 {
+			mask |= 1 << i
 		}
 	}
-	return PieceNone
+	return mask
 }
 
-// canCastle checks whether the king can peform castling in the specified direction.
-//
-// side represents a castling type:
-//   - 1 -> White O-O.
-//   - 2 -> White O-O-O.
-//   - 4 -> Black O-O.
-//   - 8 -> Black O-O-O.
-func (p *Position) canCastle(side int, attacks, occupancy uint64) bool {
-	c := bitScan(uint64(side))
-	path := castlingPath[c]
-	return p.CastlingRights&side != 0 &&
-		attacks&castlingAttackPath[c] == 0 &&
-		occupancy&path == 0
+// castlingIndex returns the index of the castling right performed by the move
+// in the order of [CastlingRights] bits.  The active color must be the color of
+// the castling king.
+func (p *Position) castlingIndex(m Move) int {
+	long := m.To()%8 == 2
+	if p.Chess960 {
+		// The king captures the rook on the queen side during O-O-O.
+		long = m.To() < m.From()
+	}
+
+	if long {
+		return 2*p.ActiveColor + 1
+	}
+	return 2 * p.ActiveColor
 }
 
 // placePiece places the piece on the specified square as well as updates the
-// occupancy and allies bitboards.
+// occupancy and allies bitboards, the mailbox and the Zobrist key.
 func (p *Position) placePiece(piece Piece, square uint64) {
+	sq := bitScan(square)
+	p.mailbox[sq] = uint8(piece + 1)
+	p.key ^= pieceKeys[piece][sq]
 	// Place the piece.
 	p.Bitboards[piece] |= square
 	// Update allies bitboard.
@@ -212,11 +341,14 @@ func (p *Position) placePiece(piece Piece, square uint64) {
 }
 
 // removePiece removes the piece from the specified square as well as updates the
-// occupancy and allies bitboards.
+// occupancy and allies bitboards, the mailbox and the Zobrist key.
 //
-// NOTE: If a piece of the specified type is not present on the specified square,
-// it will be placed rather than removed.
+// NOTE: The piece of the specified type must be present on the specified square,
+// otherwise the bitboards and the mailbox become inconsistent.
 func (p *Position) removePiece(piece Piece, square uint64) {
+	sq := bitScan(square)
+	p.mailbox[sq] = 0
+	p.key ^= pieceKeys[piece][sq]
 	// Remove the piece.
 	p.Bitboards[piece] ^= square
 	// Update allies bitboard.
@@ -225,6 +357,25 @@ func (p *Position) removePiece(piece Piece, square uint64) {
 	p.Bitboards[14] ^= square
 }
 
+// Refresh recomputes the state which is derived from the bitboards, the
+// castling rights and the active color, such as the Zobrist key.  [ParseFen]
+// and [Position.MakeMove] keep it up to date, so Refresh is only needed after
+// modifying these fields directly.
+func (p *Position) Refresh() {
+	p.mailbox = newMailbox(p.Bitboards)
+	p.key = p.zobristKey()
+}
+
+// newMailbox fills the mailbox from the piece bitboards.
+func newMailbox(bitboards [15]uint64) (mailbox [64]uint8) {
+	for piece := WPawn; piece <= BKing; piece++ {
+		for bitboard := bitboards[piece]; bitboard > 0; {
+			mailbox[popLSB(&bitboard)] = uint8(piece + 1)
+		}
+	}
+	return mailbox
+}
+
 // calculateMaterial calculates the piece valies of each side.  Used to determine
 // a draw by insufficient material.
 func (p *Position) calculateMaterial() (material int) {
@@ -236,18 +387,58 @@ func (p *Position) calculateMaterial() (material int) {
 
 // ZobristKey hashes the position into a 64-bit unsigned integer.   This allows
 // positions to be used as lookup keys and stored or compared efficiently.
-func (p *Position) ZobristKey() (key uint64) {
+//
+// Keys are compatible with the Polyglot opening book format and stay the same
+// between processes.
+//
+// The key is updated incrementally by [Position.MakeMove], so the position must
+// be created by [ParseFen] or [ParseFENStrict], or refreshed by
+// [Position.Refresh] after modifying it directly.  The en passant component
+// depends on the pawns next to the target square and is added on each call.
+func (p *Position) ZobristKey() uint64 {
+	return p.key ^ p.epKey()
+}
+
+// zobristKey computes the Zobrist key from scratch, excluding the en passant
+// component.
+func (p *Position) zobristKey() (key uint64) {
 	for i := WPawn; i <= BKing; i++ {
-		for p.Bitboards[i] > 0 {
-			key ^= pieceKeys[i][popLSB(&p.Bitboards[i])]
+		// Copy the bitboard to keep the position intact.
+		bitboard := p.Bitboards[i]
+		for bitboard > 0 {
+			key ^= pieceKeys[i][popLSB(&bitboard)]
 		}
 	}
 
-	key ^= epKeys[p.EPTarget]
-
 	key ^= castlingKeys[p.CastlingRights]
 
-	key ^= colorKey & uint64(p.ActiveColor)
+	if p.ActiveColor == ColorWhite {
+		key ^= colorKey
+	}
 
 	return key
 }
+
+// checkConsistency panics if the incrementally updated key or mailbox differs
+// from the one computed from scratch.  Only called in the builds with the
+// chegodebug tag.
+func (p *Position) checkConsistency() {
+	if expected := p.zobristKey(); p.key != expected {
+		panic(fmt.Sprintf("chego: Zobrist key %X, expected %X", p.key, expected))
+	}
+	if p.mailbox != newMailbox(p.Bitboards) {
+		panic("chego: mailbox does not match the bitboards")
+	}
+}
+
+// epKey returns the en passant component of the Zobrist key.  As in Polyglot,
+// the en passant target is hashed only if a pawn of the active color stands
+// next to the pawn which has just made a double push, even if the capture is
+// not legal.
+func (p *Position) epKey() uint64 {
+	if p.EPTarget == 0 || pawnAttacks[1^p.ActiveColor][p.EPTarget]&
+		p.Bitboards[WPawn+p.ActiveColor] == 0 {
+		return 0
+	}
+	return epKeys[p.EPTarget%8]
+}
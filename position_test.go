@@ -25,8 +25,8 @@ func TestMakeMove(t *testing.T) {
 		},
 		{
 			"black en passant",
-			"2bqkbnr/4p1pp/8/5pP1/8/3N1N2/P1PP1P1P/RqBQK2R b KQkq g4 0 1",
-			"2bqkbnr/4p1pp/8/8/6p1/3N1N2/P1PP1P1P/RqBQK2R w KQkq - 0 2",
+			"2bqkbnr/4p1pp/8/5pP1/8/3N1N2/P1PP1P1P/RqBQK2R b KQk g4 0 1",
+			"2bqkbnr/4p1pp/8/8/6p1/3N1N2/P1PP1P1P/RqBQK2R w KQk - 0 2",
 			BPawn, PieceNone, NewMove(SG4, SF5, MoveEnPassant),
 		},
 		{
@@ -37,20 +37,20 @@ func TestMakeMove(t *testing.T) {
 		},
 		{
 			"promotion",
-			"2bqkbnr/4pppp/8/8/8/3N1N2/PpPP1PPP/R1BQK2R b KQkq - 0 1",
-			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQK2R w KQkq - 0 2",
+			"2bqkbnr/4pppp/8/8/8/3N1N2/PpPP1PPP/R1BQK2R b KQk - 0 1",
+			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQK2R w KQk - 0 2",
 			BPawn, PieceNone, NewPromotionMove(SB1, SB2, PromotionQueen),
 		},
 		{
 			"white O-O",
-			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQK2R w KQkq - 0 1",
-			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 b kq - 1 1",
+			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQK2R w KQk - 0 1",
+			"2bqkbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 b k - 1 1",
 			WKing, PieceNone, NewMove(SG1, SE1, MoveCastling),
 		},
 		{
 			"black O-O-O",
-			"r3kbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 b KQkq - 0 1",
-			"2kr1bnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 w KQ - 1 2",
+			"r3kbnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 b kq - 0 1",
+			"2kr1bnr/4pppp/8/8/8/3N1N2/P1PP1PPP/RqBQ1RK1 w - - 1 2",
 			BKing, PieceNone, NewMove(SC8, SE8, MoveCastling),
 		},
 		{
@@ -77,11 +77,45 @@ func TestMakeMove(t *testing.T) {
 			"4k3/8/8/4p3/4P3/8/8/4K3 w - e6 0 2",
 			BPawn, PieceNone, NewMove(SE5, SE7, MoveNormal),
 		},
+		{
+			"Chess960 O-O with king and rook swap",
+			"4k3/8/8/8/8/8/8/5KR1 w G - 0 1",
+			"4k3/8/8/8/8/8/8/5RK1 b - - 1 1",
+			WKing, PieceNone, NewMove(SG1, SF1, MoveCastling),
+		},
+		{
+			"Chess960 O-O-O with king on destination",
+			"1rk5/8/8/8/8/8/8/4K3 b b - 0 1",
+			"2kr4/8/8/8/8/8/8/4K3 w - - 1 2",
+			BKing, PieceNone, NewMove(SB8, SC8, MoveCastling),
+		},
+		{
+			"Chess960 rook",
+			"1r4kr/8/8/8/8/8/8/4K3 b hb - 0 1",
+			"2r3kr/8/8/8/8/8/8/4K3 w h - 1 2",
+			BRook, PieceNone, NewMove(SC8, SB8, MoveNormal),
+		},
+		{
+			"rook captures rook on home square",
+			"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			"R3k2r/8/8/8/8/8/8/4K2R b Kk - 0 1",
+			WRook, BRook, NewMove(SA8, SA1, MoveNormal),
+		},
+		{
+			"Chess960 rook captured on home square",
+			"1r4kr/8/8/8/8/8/8/1R2K3 w hb - 0 1",
+			"1R4kr/8/8/8/8/8/8/4K3 b h - 0 1",
+			WRook, BRook, NewMove(SB8, SB1, MoveNormal),
+		},
 	}
 
 	for _, tc := range cases {
 		pos := ParseFen(tc.fenStr)
-		pos.MakeMove(tc.move, tc.moved, tc.captured)
+		u := pos.MakeMove(tc.move)
+		if u.Moved != tc.moved || u.Captured != tc.captured {
+			t.Fatalf("test \"%s\" failed: expected moved %d and captured %d, got %d and %d",
+				tc.name, tc.moved, tc.captured, u.Moved, u.Captured)
+		}
 
 		got := SerializeFen(pos)
 		if got != tc.expected {
@@ -90,6 +124,40 @@ func TestMakeMove(t *testing.T) {
 	}
 }
 
+func TestUnmakeMove(t *testing.T) {
+	fens := []string{
+		InitialPos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+		"2bqkbnr/4p1pp/8/5pP1/8/3N1N2/P1PP1P1P/RqBQK2R b KQkq g4 0 1",
+		"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		"1rk5/8/8/8/8/8/8/4K3 b b - 0 1",
+	}
+
+	for _, fen := range fens {
+		p := ParseFen(fen)
+		before := *p
+
+		var legal MoveList
+		GenLegalMoves(*p, &legal)
+
+		for i := range legal.Len {
+			m := legal.Moves[i]
+			u := p.MakeMove(m)
+			p.UnmakeMove(m, u)
+
+			if *p != before {
+				t.Fatalf("%s: move %s: expected %s, got %s", fen, m.UCI(),
+					fen, SerializeFen(p))
+			}
+		}
+	}
+}
+
 func TestIsInsufficientMaterial(t *testing.T) {
 	cases := []struct {
 		fen      string
@@ -116,12 +184,97 @@ func TestIsInsufficientMaterial(t *testing.T) {
 	}
 }
 
+// TestZobristKey compares the keys with the test values from the Polyglot book
+// format specification.
+func TestZobristKey(t *testing.T) {
+	cases := []struct {
+		fen      string
+		expected uint64
+	}{
+		{InitialPos, 0x463B96181691FC9C},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823C9B50FD114196},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756B94461C50FB0},
+		{"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662FAFB965DB29D4},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22A48B5A8E47FF78},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652A607CA3F242C1},
+		{"rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00FDD303C946BDD9},
+		{"rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3C8123EA7B067637},
+		{"rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5C3F9B829B279560},
+	}
+
+	for _, tc := range cases {
+		p := ParseFen(tc.fen)
+		before := *p
+
+		if got := p.ZobristKey(); got != tc.expected {
+			t.Fatalf("%s: expected %X, got %X", tc.fen, tc.expected, got)
+		}
+		if *p != before {
+			t.Fatalf("%s: position must not be modified", tc.fen)
+		}
+	}
+}
+
+// TestIncrementalUpdate walks the move generation tree and compares the
+// incrementally updated keys and mailboxes with the ones computed from scratch.
+func TestIncrementalUpdate(t *testing.T) {
+	fens := []string{
+		InitialPos,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	}
+
+	var walk func(p *Position, depth int)
+	walk = func(p *Position, depth int) {
+		if expected := p.zobristKey() ^ p.epKey(); p.ZobristKey() != expected {
+			t.Fatalf("%s: expected %X, got %X", SerializeFen(p), expected,
+				p.ZobristKey())
+		}
+		if p.mailbox != newMailbox(p.Bitboards) {
+			t.Fatalf("%s: mailbox does not match the bitboards", SerializeFen(p))
+		}
+		if depth == 0 {
+			return
+		}
+
+		var l MoveList
+		GenLegalMoves(*p, &l)
+		for i := range l.Len {
+			m := l.Moves[i]
+			u := p.MakeMove(m)
+			walk(p, depth-1)
+			p.UnmakeMove(m, u)
+		}
+	}
+
+	for _, fen := range fens {
+		p := ParseFen(fen)
+		before := *p
+		walk(p, 3)
+		if *p != before {
+			t.Fatalf("%s: position is not restored", fen)
+		}
+	}
+}
+
 func BenchmarkMakeMove(b *testing.B) {
 	before := ParseFen("rnbqkbnr/pppppppp/8/8/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 1")
 
 	for b.Loop() {
 		pos := before
-		pos.MakeMove(NewMove(SG1, SE1, MoveCastling), WKing, PieceNone)
+		pos.MakeMove(NewMove(SG1, SE1, MoveCastling))
+	}
+}
+
+func BenchmarkUnmakeMove(b *testing.B) {
+	pos := ParseFen("rnbqkbnr/pppppppp/8/8/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 1")
+	m := NewMove(SG1, SE1, MoveCastling)
+
+	for b.Loop() {
+		u := pos.MakeMove(m)
+		pos.UnmakeMove(m, u)
 	}
 }
 
@@ -142,3 +295,27 @@ func BenchmarkZobristKey(b *testing.B) {
 		p.ZobristKey()
 	}
 }
+
+func TestRefresh(t *testing.T) {
+	// The zero value is the empty board.
+	var p Position
+	if p.GetPieceFromSquare(1<<SE4) != PieceNone || p.GetPieceFromSquare(0) != PieceNone {
+		t.Fatalf("zero position must be empty")
+	}
+
+	expected := ParseFen("4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	p = Position{
+		Bitboards:      ParseBitboards("4k3/8/8/8/8/8/8/4K2R"),
+		CastlingRights: expected.CastlingRights,
+		FullmoveCnt:    1,
+		CastlingRooks:  expected.CastlingRooks,
+	}
+	p.Refresh()
+
+	if p != *expected {
+		t.Fatalf("expected %+v, got %+v", *expected, p)
+	}
+	if p.GetPieceFromSquare(1<<SH1) != WRook {
+		t.Fatalf("expected rook on h1, got %d", p.GetPieceFromSquare(1<<SH1))
+	}
+}
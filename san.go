@@ -1,9 +1,24 @@
-// san.go implements serialization of moves into Standard Algebraic Notation.
+// san.go implements serialization and parsing of moves in Standard Algebraic
+// Notation.
 // See http://www.saremba.de/chessgml/standards/pgn/pgn-complete.htm Section 8.2.3.
 
 package chego
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidSAN is returned when the SAN string is malformed.
+	ErrInvalidSAN = errors.New("invalid SAN")
+	// ErrIllegalMove is returned when the move is not legal in the position.
+	ErrIllegalMove = errors.New("illegal move")
+	// ErrAmbiguousMove is returned when the SAN string matches multiple legal
+	// moves.
+	ErrAmbiguousMove = errors.New("ambiguous move")
+)
 
 // Move2SAN encodes the specified move to its SAN representation.
 //
@@ -27,7 +42,7 @@ func Move2SAN(m Move, p *Position, lm *MoveList) string {
 	captured := p.GetPieceFromSquare(1 << m.To())
 
 	if m.Type() == MoveCastling {
-		if m.To() == SC1 || m.To() == SC8 {
+		if p.castlingIndex(m)%2 == 1 {
 			b.WriteString("O-O-O")
 		} else {
 			b.WriteString("O-O")
@@ -90,7 +105,7 @@ func Move2SAN(m Move, p *Position, lm *MoveList) string {
 		}
 	}
 
-	p.MakeMove(m, moved, captured)
+	p.MakeMove(m)
 
 	GenLegalMoves(*p, lm)
 
@@ -98,7 +113,7 @@ func Move2SAN(m Move, p *Position, lm *MoveList) string {
 	ep := 0
 	// If the en passant capture is not possible, clear the en passant target,
 	// since it can break the threefold-repetition detection by corrupting the
-	// Zobrish hash.  See [IsThreefoldRepetition] function commentary.
+	// Zobrist hash.  See [Game.positionKey] function commentary.
 	for i := range lm.Len {
 		if lm.Moves[i].Type() == MoveEnPassant {
 			ep = p.EPTarget
@@ -158,3 +173,129 @@ func disambiguate(from int, ambiguities []int) string {
 	// Step 3.
 	return Square2String[from]
 }
+
+// ParseSAN converts the SAN string into the legal move in the specified
+// position.  The position is not modified.
+//
+// Besides the strict SAN, the following variations are accepted:
+//   - Promotions without the '=' sign, e.g. "e8Q";
+//   - Castlings written with zeros, e.g. "0-0-0";
+//   - Missing or redundant capture sign and disambiguation;
+//   - Trailing check, checkmate and annotation symbols ('+', '#', '!', '?').
+//
+// Returns [ErrInvalidSAN] if the string is malformed, [ErrIllegalMove] if no
+// legal move matches it, and [ErrAmbiguousMove] if several legal moves match it.
+func ParseSAN(s string, p *Position) (Move, error) {
+	san := strings.TrimRight(s, "+#!?")
+	if len(san) < 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSAN, s)
+	}
+
+	var legal MoveList
+	GenLegalMoves(*p, &legal)
+
+	// Handle castlings.
+	switch san {
+	case "O-O", "0-0", "O-O-O", "0-0-0":
+		isLong := len(san) == 5
+		for i := range legal.Len {
+			m := legal.Moves[i]
+			if m.Type() == MoveCastling && (p.castlingIndex(m)%2 == 1) == isLong {
+				return m, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: %q", ErrIllegalMove, s)
+	}
+
+	// Parse the moving piece.  Pawns are represented by WPawn.
+	piece := WPawn
+	switch san[0] {
+	case 'N':
+		piece = WKnight
+	case 'B':
+		piece = WBishop
+	case 'R':
+		piece = WRook
+	case 'Q':
+		piece = WQueen
+	case 'K':
+		piece = WKing
+	}
+	if piece != WPawn {
+		san = san[1:]
+	}
+
+	// Parse the promotion piece.
+	promo := -1
+	if n := len(san); n > 0 {
+		switch san[n-1] {
+		case 'N':
+			promo = PromotionKnight
+		case 'B':
+			promo = PromotionBishop
+		case 'R':
+			promo = PromotionRook
+		case 'Q':
+			promo = PromotionQueen
+		}
+		if promo != -1 {
+			san = strings.TrimSuffix(san[:n-1], "=")
+		}
+	}
+
+	// Parse the destination square.
+	n := len(san)
+	if n < 2 || !isFile(san[n-2]) || !isRank(san[n-1]) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSAN, s)
+	}
+	to := int(san[n-2]-'a') + 8*int(san[n-1]-'1')
+	san = strings.TrimSuffix(san[:n-2], "x")
+
+	// Parse the disambiguation.
+	fromFile, fromRank := -1, -1
+	for i := range len(san) {
+		switch {
+		case isFile(san[i]) && fromFile == -1 && fromRank == -1:
+			fromFile = int(san[i] - 'a')
+		case isRank(san[i]) && fromRank == -1:
+			fromRank = int(san[i] - '1')
+		default:
+			return 0, fmt.Errorf("%w: %q", ErrInvalidSAN, s)
+		}
+	}
+
+	found, cnt := Move(0), 0
+	for i := range legal.Len {
+		m := legal.Moves[i]
+		moved := p.GetPieceFromSquare(1 << m.From())
+
+		if m.To() != to || moved-moved%2 != piece ||
+			(fromFile != -1 && m.From()%8 != fromFile) ||
+			(fromRank != -1 && m.From()/8 != fromRank) {
+			continue
+		}
+
+		if m.Type() == MovePromotion {
+			if promo != m.PromoPiece() {
+				continue
+			}
+		} else if promo != -1 {
+			continue
+		}
+
+		found = m
+		cnt++
+	}
+
+	switch cnt {
+	case 0:
+		return 0, fmt.Errorf("%w: %q", ErrIllegalMove, s)
+	case 1:
+		return found, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrAmbiguousMove, s)
+	}
+}
+
+func isFile(c byte) bool { return c >= 'a' && c <= 'h' }
+func isRank(c byte) bool { return c >= '1' && c <= '8' }
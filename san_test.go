@@ -1,6 +1,9 @@
 package chego
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestMove2SAN(t *testing.T) {
 	cases := []struct {
@@ -63,6 +66,62 @@ func TestMove2SAN(t *testing.T) {
 	}
 }
 
+func TestParseSAN(t *testing.T) {
+	cases := []struct {
+		san      string
+		fen      string
+		expected Move
+		err      error
+	}{
+		{"Nce2", "8/8/8/8/8/2N5/8/4K1N1 w - - 0 1", NewMove(SE2, SC3, MoveNormal), nil},
+		{"Ne2", "8/8/8/8/8/2N5/8/4K1N1 w - - 0 1", 0, ErrAmbiguousMove},
+		// The knight c3 is pinned, so the move is not ambiguous.
+		{"Ne2", "8/8/8/8/1b6/2N5/8/4K1N1 w - - 0 1", NewMove(SE2, SG1, MoveNormal), nil},
+		{"Q6xb7#", "2k5/Qr6/Q7/8/8/8/8/3R4 w - - 0 1", NewMove(SB7, SA6, MoveNormal), nil},
+		{"Qa6b7", "2k5/Qr6/Q7/8/8/8/8/3R4 w - - 0 1", NewMove(SB7, SA6, MoveNormal), nil},
+		{"Qxb7", "2k5/Qr6/Q7/8/8/8/8/3R4 w - - 0 1", 0, ErrAmbiguousMove},
+		{"Q5b8", "Q3Q2Q/8/8/4Q3/4P3/2N5/3k2P1/R5K1 w - - 0 1", NewMove(SB8, SE5, MoveNormal), nil},
+		{"dxe8=Q", "4b3/3P1P2/8/8/8/8/8/8 w - - 0 1", NewPromotionMove(SE8, SD7, PromotionQueen), nil},
+		{"fxe8N!?", "4b3/3P1P2/8/8/8/8/8/8 w - - 0 1", NewPromotionMove(SE8, SF7, PromotionKnight), nil},
+		{"de8R", "4b3/3P1P2/8/8/8/8/8/8 w - - 0 1", NewPromotionMove(SE8, SD7, PromotionRook), nil},
+		{"xe8=Q", "4b3/3P1P2/8/8/8/8/8/8 w - - 0 1", 0, ErrAmbiguousMove},
+		{"d8", "4b3/3P1P2/8/8/8/8/8/8 w - - 0 1", 0, ErrIllegalMove},
+		{"exd4+", "8/8/8/4p3/3P4/2K5/8/8 b - - 0 1", NewMove(SD4, SE5, MoveNormal), nil},
+		{"exd6", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", NewMove(SD6, SE5, MoveEnPassant), nil},
+		{"O-O", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", NewMove(SG1, SE1, MoveCastling), nil},
+		{"0-0-0", "r3k3/8/8/8/8/8/8/4K3 b q - 0 1", NewMove(SC8, SE8, MoveCastling), nil},
+		{"O-O-O", "r3k3/8/8/8/8/8/8/4K3 b - - 0 1", 0, ErrIllegalMove},
+		{"Ke3", InitialPos, 0, ErrIllegalMove},
+		{"Nf9", InitialPos, 0, ErrInvalidSAN},
+		{"Zf3", InitialPos, 0, ErrInvalidSAN},
+		{"+", InitialPos, 0, ErrInvalidSAN},
+	}
+
+	for _, tc := range cases {
+		p := ParseFen(tc.fen)
+		before := *p
+
+		got, err := ParseSAN(tc.san, p)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected error %v, got %v", tc.san, tc.err, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%s: expected move %d, got %d", tc.san, tc.expected, got)
+		}
+		if *p != before {
+			t.Fatalf("%s: position must not be modified", tc.san)
+		}
+	}
+}
+
+func BenchmarkParseSAN(b *testing.B) {
+	p := ParseFen("Q3Q2Q/8/8/4Q3/4P3/2N5/3k2P1/R5K1 w - - 0 1")
+
+	for b.Loop() {
+		ParseSAN("Q5b8", p)
+	}
+}
+
 func BenchmarkMove2SAN(b *testing.B) {
 	p := ParseFen("r1bk3r/ppqpbQpp/2p4n/6B1/2BpP3/3P1P2/PPP3PP/RN3RK1 w - - 0 1")
 	var legal MoveList
@@ -0,0 +1,113 @@
+// see.go implements the static exchange evaluation.
+
+package chego
+
+// PieceWeights maps each piece type, except kings, to its value.  Use Piece type
+// as index to get it's weight.
+type PieceWeights [10]int
+
+// seeKingWeight is the value of the king in the exchange.  It exceeds the sum
+// of all other pieces with any sane weights, so the king never recaptures on
+// the defended square.
+const seeKingWeight = 1 << 30
+
+// SEE statically evaluates the exchange of pieces on the destination square of
+// the move using the default piece weights: 1 for pawns, 3 for knights and
+// bishops, 5 for rooks, and 9 for queens.  See [SEEWithWeights] for details.
+func SEE(p *Position, m Move) int {
+	return SEEWithWeights(p, m, pieceWeights)
+}
+
+// SEEWithWeights statically evaluates the exchange of pieces on the destination
+// square of the move using the specified piece values.  Returns the material
+// balance from the perspective of the side which makes the move, assuming that
+// both sides recapture with the least valuable attacker and stop capturing once
+// it becomes unprofitable.  The move itself is always made, so the result is
+// negative if the moved piece is lost.
+//
+// X-ray attackers behind the moved pieces join the exchange.  Pins are ignored,
+// and only the promotion of the move itself is taken into account.  Castling
+// moves are evaluated as 0.
+func SEEWithWeights(p *Position, m Move, weights PieceWeights) int {
+	if m.Type() == MoveCastling {
+		return 0
+	}
+
+	weight := func(piece Piece) int {
+		if piece == WKing || piece == BKing {
+			return seeKingWeight
+		}
+		return weights[piece]
+	}
+
+	to := m.To()
+	from := uint64(1 << m.From())
+	occupancy := p.Bitboards[14] ^ from
+
+	// gain[d] stores the material balance of the d-th capture from the
+	// perspective of the capturing side.
+	var gain [32]int
+	captured := p.GetPieceFromSquare(1 << to)
+	if m.Type() == MoveEnPassant {
+		// Remove the captured pawn, since it may block the x-ray attackers.
+		captured = WPawn + (1 ^ p.ActiveColor)
+		if p.ActiveColor == ColorWhite {
+			occupancy ^= 1 << (to - 8)
+		} else {
+			occupancy ^= 1 << (to + 8)
+		}
+	}
+	if captured != PieceNone {
+		gain[0] = weight(captured)
+	}
+
+	// The weight of the piece which stands on the square after the capture.
+	onSquare := weight(p.GetPieceFromSquare(from))
+	if m.Type() == MovePromotion {
+		promoted := WKnight + 2*m.PromoPiece()
+		gain[0] += weight(promoted) - weight(WPawn)
+		onSquare = weight(promoted)
+	}
+
+	bishops := p.Bitboards[WBishop] | p.Bitboards[BBishop] |
+		p.Bitboards[WQueen] | p.Bitboards[BQueen]
+	rooks := p.Bitboards[WRook] | p.Bitboards[BRook] |
+		p.Bitboards[WQueen] | p.Bitboards[BQueen]
+
+	attackers := p.AttackersTo(to, occupancy)
+	side := 1 ^ p.ActiveColor
+
+	d := 0
+	for d+1 < len(gain) {
+		// Find the least valuable attacker of the side.
+		attacker := PieceNone
+		var attackerBB uint64
+		for piece := WPawn + side; piece <= BKing; piece += 2 {
+			if bb := attackers & p.Bitboards[piece]; bb != 0 {
+				attacker, attackerBB = piece, bb&-bb
+				break
+			}
+		}
+		if attacker == PieceNone {
+			break
+		}
+
+		d++
+		gain[d] = onSquare - gain[d-1]
+		onSquare = weight(attacker)
+
+		// Remove the attacker and reveal the x-ray attackers behind it.
+		occupancy ^= attackerBB
+		attackers |= lookupBishopAttacks(to, occupancy)&bishops |
+			lookupRookAttacks(to, occupancy)&rooks
+		attackers &= occupancy
+
+		side ^= 1
+	}
+
+	// Each side may stop capturing if it's unprofitable.
+	for ; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
@@ -0,0 +1,93 @@
+package chego
+
+import "testing"
+
+func TestAttackersTo(t *testing.T) {
+	p := ParseFen("4k3/2n5/8/3p4/8/3R4/3Q4/4K3 w - - 0 1")
+
+	expected := D3 | C7
+	if got := p.AttackersTo(SD5, p.Bitboards[14]); got != expected {
+		t.Fatalf("expected %X, got %X", expected, got)
+	}
+
+	// The queen x-rays through the rook.
+	expected = D2 | C7
+	if got := p.AttackersTo(SD5, p.Bitboards[14]^D3); got != expected {
+		t.Fatalf("expected %X, got %X", expected, got)
+	}
+}
+
+func TestSEE(t *testing.T) {
+	cases := []struct {
+		name     string
+		fen      string
+		move     Move
+		expected int
+	}{
+		{
+			"undefended pawn",
+			"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1",
+			NewMove(SE5, SE1, MoveNormal), 1,
+		},
+		{
+			"defended pawn with x-ray attackers",
+			"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1",
+			NewMove(SE5, SD3, MoveNormal), -2,
+		},
+		{
+			"equal trade",
+			"4k3/8/8/3p4/4N3/8/8/3RK3 b - - 0 1",
+			NewMove(SE4, SD5, MoveNormal), 3,
+		},
+		{
+			"quiet move to the attacked square",
+			"4k3/8/8/4p3/8/8/8/3QK3 w - - 0 1",
+			NewMove(SD4, SD1, MoveNormal), -9,
+		},
+		{
+			"king cannot recapture on the defended square",
+			"3rk3/8/8/8/8/1n6/3r4/3QK3 w - - 0 1",
+			NewMove(SD2, SD1, MoveNormal), -4,
+		},
+		{
+			"en passant",
+			"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+			NewMove(SD6, SE5, MoveEnPassant), 1,
+		},
+		{
+			"capture promotion",
+			"1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1",
+			NewPromotionMove(SB8, SA7, PromotionQueen), 13,
+		},
+		{
+			"promotion on the attacked square",
+			"1r2k3/P7/8/8/8/8/8/4K3 w - - 0 1",
+			NewPromotionMove(SA8, SA7, PromotionQueen), -1,
+		},
+	}
+
+	for _, tc := range cases {
+		if got := SEE(ParseFen(tc.fen), tc.move); got != tc.expected {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestSEEWithWeights(t *testing.T) {
+	weights := PieceWeights{100, 100, 320, 320, 330, 330, 500, 500, 900, 900}
+	p := ParseFen("4k3/8/3p4/4b3/8/8/8/4RK2 w - - 0 1")
+
+	// Bishop is defended by the pawn.
+	if got := SEEWithWeights(p, NewMove(SE5, SE1, MoveNormal), weights); got != -170 {
+		t.Fatalf("expected -170, got %d", got)
+	}
+}
+
+func BenchmarkSEE(b *testing.B) {
+	p := ParseFen("1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1")
+	m := NewMove(SE5, SD3, MoveNormal)
+
+	for b.Loop() {
+		SEE(p, m)
+	}
+}
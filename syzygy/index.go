@@ -0,0 +1,343 @@
+// index.go implements the mapping of the positions into the table indices.
+
+package syzygy
+
+import (
+	"math/bits"
+
+	"github.com/treepeck/chego"
+)
+
+// maxPieces is the maximum number of pieces in the supported tables.
+const maxPieces = 7
+
+var (
+	// binomial[k][n] is the number of ways to choose k elements from a set of
+	// n elements.
+	binomial = initBinomial()
+	// mapA1D1D4 maps the squares of the a1-d1-d4 triangle to 0..9.  Squares
+	// below the a1-h8 diagonal come first.
+	mapA1D1D4 = initMapA1D1D4()
+	// mapB1H1H7 maps the squares below the a1-h8 diagonal to 0..27.
+	mapB1H1H7 = initMapB1H1H7()
+	// mapKK maps the legal placements of two kings, where the first king is
+	// in the a1-d1-d4 triangle, to 0..461.
+	mapKK = initMapKK()
+	// mapPawns maps the squares a2-h7 to 0..47, so that the leading pawn, the
+	// one nearest to the edge with the lowest rank, has the highest value.
+	// leadPawnIdx and leadPawnsSize store the index of the leading pawn group
+	// for each number of the leading pawns and the number of placements of
+	// the group for each file.
+	mapPawns, leadPawnIdx, leadPawnsSize = initPawnIndices()
+)
+
+// initBinomial computes the binomial coefficients using the Pascal's rule.
+func initBinomial() (b [maxPieces][64]uint64) {
+	b[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < maxPieces && k <= n; k++ {
+			if k > 0 {
+				b[k][n] += b[k-1][n-1]
+			}
+			if k < n {
+				b[k][n] += b[k][n-1]
+			}
+		}
+	}
+	return b
+}
+
+func initMapA1D1D4() (m [64]int) {
+	code := 0
+	for s := chego.SA1; s <= chego.SD4; s++ {
+		if offA1H8(s) < 0 && s%8 <= 3 {
+			m[s] = code
+			code++
+		}
+	}
+	for s := chego.SA1; s <= chego.SD4; s++ {
+		if offA1H8(s) == 0 && s%8 <= 3 {
+			m[s] = code
+			code++
+		}
+	}
+	return m
+}
+
+func initMapB1H1H7() (m [64]int) {
+	code := 0
+	for s := range 64 {
+		if offA1H8(s) < 0 {
+			m[s] = code
+			code++
+		}
+	}
+	return m
+}
+
+func initMapKK() (m [10][64]int) {
+	type placement struct{ idx, s2 int }
+	// Placements with both kings on the diagonal are encoded last.
+	var bothOnDiagonal []placement
+
+	code := 0
+	for idx := range 10 {
+		for s1 := chego.SA1; s1 <= chego.SD4; s1++ {
+			// b1 is mapped to 0 as well as the squares outside the triangle.
+			if mapA1D1D4[s1] != idx || (idx == 0 && s1 != chego.SB1) {
+				continue
+			}
+
+			for s2 := range 64 {
+				switch {
+				case s1 == s2 || kingDistance(s1, s2) <= 1:
+					// Kings cannot stand next to each other.
+				case offA1H8(s1) == 0 && offA1H8(s2) > 0:
+					// The first king is on the diagonal, the second is above.
+				case offA1H8(s1) == 0 && offA1H8(s2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, placement{idx, s2})
+				default:
+					m[idx][s2] = code
+					code++
+				}
+			}
+		}
+	}
+
+	for _, p := range bothOnDiagonal {
+		m[p.idx][p.s2] = code
+		code++
+	}
+	return m
+}
+
+func initPawnIndices() (m [64]int, idx [6][64]uint64, size [6][4]uint64) {
+	// Available squares when the leading pawn is on a2.
+	available := 47
+
+	for cnt := 1; cnt <= 5; cnt++ {
+		for f := range 4 {
+			// The index restarts at every file, since the tables are split
+			// by the file of the leading pawn.
+			var i uint64
+			for r := 1; r <= 6; r++ {
+				s := r*8 + f
+				// There are 2 less available squares with each rank due to
+				// the mirroring: a3 excludes a2 and h2.
+				if cnt == 1 {
+					m[s] = available
+					m[s^7] = available - 1
+					available -= 2
+				}
+				idx[cnt][s] = i
+				i += binomial[cnt-1][m[s]]
+			}
+			size[cnt][f] = i
+		}
+	}
+	return m, idx, size
+}
+
+// offA1H8 returns the signed distance of the square from the a1-h8 diagonal.
+// Positive values are above the diagonal.
+func offA1H8(s int) int {
+	return s/8 - s%8
+}
+
+// flipDiag mirrors the square along the a1-h8 diagonal.
+func flipDiag(s int) int {
+	return ((s >> 3) | (s << 3)) & 63
+}
+
+// kingDistance returns the number of king moves between the squares.
+func kingDistance(s1, s2 int) int {
+	return max(abs(s1/8-s2/8), abs(s1%8-s2%8))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// tbPiece converts the chego piece into the piece code used in the tables:
+// 1..6 for the white pawn..king and 9..14 for the black ones.
+func tbPiece(p chego.Piece) int {
+	return p/2 + 1 | (p%2)<<3
+}
+
+// encode maps the position into the index of the pairs data which stores it.
+// Also returns the file of the leading pawn, since the tables with pawns are
+// split by it.
+//
+// flip swaps the colors and mirrors the ranks, since the tables are stored
+// with the stronger side being white.  stm is the side to move after the flip.
+func (t *table) encode(p *chego.Position, flip bool, stm int) (*pairsData, uint64, int) {
+	var squares, pieces [maxPieces]int
+	var leadPawns uint64
+	size, leadCnt, tbFile := 0, 0, 0
+
+	flipColor, flipSquares := 0, 0
+	if flip {
+		flipColor, flipSquares = 8, 56
+	}
+
+	if t.hasPawns {
+		// Pawns of the leading color are always the first pieces.
+		pc := t.get(0, 0).pieces[0] ^ flipColor
+		leadPawns = p.Bitboards[chego.WPawn+pc>>3]
+		for b := leadPawns; b != 0; b &= b - 1 {
+			squares[size] = bits.TrailingZeros64(b) ^ flipSquares
+			size++
+		}
+		leadCnt = size
+
+		// The leading pawn has the highest mapPawns value.
+		lead := 0
+		for i := 1; i < leadCnt; i++ {
+			if mapPawns[squares[i]] > mapPawns[squares[lead]] {
+				lead = i
+			}
+		}
+		squares[0], squares[lead] = squares[lead], squares[0]
+		tbFile = min(squares[0]%8, 7-squares[0]%8)
+	}
+
+	for b := p.Bitboards[14] ^ leadPawns; b != 0; b &= b - 1 {
+		s := bits.TrailingZeros64(b)
+		squares[size] = s ^ flipSquares
+		pieces[size] = tbPiece(p.GetPieceFromSquare(1<<s)) ^ flipColor
+		size++
+	}
+
+	d := t.get(stm, tbFile)
+
+	// Reorder the pieces to match the sequence stored in the table.
+	for i := leadCnt; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror the board so the leading piece is on the files a-d.
+	if squares[0]%8 > 3 {
+		for i := range size {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = leadPawnIdx[leadCnt][squares[0]]
+
+		// Encode the rest of the leading pawns in ascending mapPawns order.
+		for i := 2; i < leadCnt; i++ {
+			for j := i; j > 1 && mapPawns[squares[j]] < mapPawns[squares[j-1]]; j-- {
+				squares[j], squares[j-1] = squares[j-1], squares[j]
+			}
+		}
+		for i := 1; i < leadCnt; i++ {
+			idx += binomial[i][mapPawns[squares[i]]]
+		}
+	} else {
+		idx = t.encodePieces(d, squares[:size])
+	}
+	idx *= d.groupIdx[0]
+
+	// Encode the remaining groups with the squares in ascending order.
+	remainingPawns := t.hasPawns && t.pawnCnt[1] > 0
+	start := d.groupLen[0]
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		for i := 1; i < len(group); i++ {
+			for j := i; j > 0 && group[j] < group[j-1]; j-- {
+				group[j], group[j-1] = group[j-1], group[j]
+			}
+		}
+
+		var n uint64
+		for i, s := range group {
+			// Skip the squares occupied by the previous groups.
+			adjust := 0
+			for _, prev := range squares[:start] {
+				if s > prev {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += binomial[i+1][s-adjust]
+		}
+
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		start += len(group)
+	}
+
+	return d, idx, tbFile
+}
+
+// encodePieces maps the leading group of the pawnless position into the index.
+// The leading group is either three unique pieces or the pair of kings.
+func (t *table) encodePieces(d *pairsData, squares []int) uint64 {
+	// Mirror the board so the leading piece is on the ranks 1-4.
+	if squares[0]/8 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+
+	// The first piece of the leading group which is not on the a1-h8 diagonal
+	// must be below it.
+	for i := range d.groupLen[0] {
+		off := offA1H8(squares[i])
+		if off == 0 {
+			continue
+		}
+		if off > 0 {
+			for j := i; j < len(squares); j++ {
+				squares[j] = flipDiag(squares[j])
+			}
+		}
+		break
+	}
+
+	if !t.hasUniquePieces {
+		return uint64(mapKK[mapA1D1D4[squares[0]]][squares[1]])
+	}
+
+	// Skip the squares occupied by the previous pieces of the group.
+	adjust1, adjust2 := 0, 0
+	if squares[1] > squares[0] {
+		adjust1 = 1
+	}
+	if squares[2] > squares[0] {
+		adjust2++
+	}
+	if squares[2] > squares[1] {
+		adjust2++
+	}
+
+	var idx int
+	switch {
+	case offA1H8(squares[0]) != 0:
+		idx = (mapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 +
+			squares[2] - adjust2
+	case offA1H8(squares[1]) != 0:
+		idx = (6*63+squares[0]/8*28+mapB1H1H7[squares[1]])*62 +
+			squares[2] - adjust2
+	case offA1H8(squares[2]) != 0:
+		idx = 6*63*62 + 4*28*62 + squares[0]/8*7*28 +
+			(squares[1]/8-adjust1)*28 + mapB1H1H7[squares[2]]
+	default:
+		idx = 6*63*62 + 4*28*62 + 4*7*28 + squares[0]/8*7*6 +
+			(squares[1]/8-adjust1)*6 + squares[2]/8 - adjust2
+	}
+	return uint64(idx)
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package syzygy
+
+import "os"
+
+// mapFile reads the whole file, since memory mapping is not supported on this
+// platform.
+func mapFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// unmapFile releases the data returned by [mapFile].
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package syzygy
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the file into memory read-only, so the pages of the large tables
+// are only read when probed and can be evicted by the system.  Returns nil for
+// the empty file.
+func mapFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, nil
+	}
+	return syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ,
+		syscall.MAP_SHARED)
+}
+
+// unmapFile releases the data returned by [mapFile].
+func unmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return syscall.Munmap(data)
+}
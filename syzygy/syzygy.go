@@ -0,0 +1,532 @@
+// Package syzygy implements probing of the Syzygy endgame tablebases stored in
+// the local files.
+//
+// WDL tables (.rtbw) store whether the position is won, drawn or lost, and DTZ
+// tables (.rtbz) store the distance to the next capture or pawn move, which
+// resets the halfmove counter, for the optimal play.  Both take the 50-move
+// rule into account.
+//
+// See https://github.com/syzygy1/tb.
+package syzygy
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/treepeck/chego"
+)
+
+var (
+	// ErrMissingTable is returned when the table for the material on the
+	// board is not available.
+	ErrMissingTable = errors.New("missing table")
+	// ErrCastling is returned when the position has castling rights, since
+	// the tables don't store such positions.
+	ErrCastling = errors.New("position has castling rights")
+	// ErrCorrupted is returned when the table file cannot be parsed.
+	ErrCorrupted = errors.New("corrupted table")
+)
+
+// maxDTZ is used to rank the root moves.  Exceeds any DTZ value.
+const maxDTZ = 1 << 18
+
+// WDL is the result of the position from the perspective of the active color.
+type WDL int
+
+const (
+	Loss WDL = iota - 2
+	// BlessedLoss is the loss which is a draw under the 50-move rule.
+	BlessedLoss
+	Draw
+	// CursedWin is the win which is a draw under the 50-move rule.
+	CursedWin
+	Win
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "loss"
+	case BlessedLoss:
+		return "blessed loss"
+	case Draw:
+		return "draw"
+	case CursedWin:
+		return "cursed win"
+	case Win:
+		return "win"
+	}
+	return fmt.Sprintf("WDL(%d)", int(w))
+}
+
+// probeState is the additional outcome of the internal probes.
+type probeState int
+
+const (
+	stateOK probeState = iota
+	// The DTZ table stores the positions of the other side to move.
+	stateChangeSTM
+	// The best move is a capture or a pawn move, so the DTZ table stores a
+	// "don't care" value.
+	stateZeroingBestMove
+)
+
+// Tablebase provides access to the tables in a directory.  Tables are mapped
+// into memory on the first probe and stay mapped until [Tablebase.Close].
+// Tablebase is safe for concurrent use.
+type Tablebase struct {
+	// Tables indexed by the material keys of both colors.
+	wdl, dtz  map[uint64]*table
+	maxPieces int
+}
+
+// Open finds the tables in the specified directory.  Files with unknown names
+// are ignored.
+func Open(dir string) (*Tablebase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tb := &Tablebase{
+		wdl: make(map[uint64]*table),
+		dtz: make(map[uint64]*table),
+	}
+	for _, e := range entries {
+		name, ext, _ := strings.Cut(e.Name(), ".")
+		if e.IsDir() || ext != "rtbw" && ext != "rtbz" {
+			continue
+		}
+
+		t, ok := newTable(filepath.Join(dir, e.Name()), name, ext == "rtbz")
+		if !ok {
+			continue
+		}
+
+		tables := tb.wdl
+		if t.dtz {
+			tables = tb.dtz
+		}
+		tables[t.key] = t
+		tables[t.key2] = t
+		tb.maxPieces = max(tb.maxPieces, t.pieceCnt)
+	}
+	return tb, nil
+}
+
+// Close releases the memory of the loaded tables.  The tablebase must not be
+// used after Close.
+func (tb *Tablebase) Close() error {
+	var err error
+	for _, tables := range []map[uint64]*table{tb.wdl, tb.dtz} {
+		for _, t := range tables {
+			// Tables are stored under the keys of both colors.
+			if t.data != nil {
+				err = errors.Join(err, unmapFile(t.data))
+				t.data = nil
+			}
+		}
+	}
+	return err
+}
+
+// MaxPieces returns the number of pieces, including kings, in the largest
+// table found.
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// ProbeWDL returns the result of the position under the 50-move rule.  The WDL
+// tables of the positions reachable by the captures are required as well.
+//
+// If [chego.Position.HalfmoveCnt] is not zero, the won and lost positions are
+// probed in the DTZ tables too, since the remaining moves may not suffice to
+// reach the next capture or pawn move.  Such positions are reported as
+// [CursedWin] and [BlessedLoss], see [Tablebase.ProbeDTZ].
+func (tb *Tablebase) ProbeWDL(p *chego.Position) (WDL, error) {
+	if p.CastlingRights != 0 {
+		return Draw, ErrCastling
+	}
+
+	pos := *p
+	wdl, _, err := tb.search(&pos, false)
+	if err != nil || pos.HalfmoveCnt == 0 || wdl != Win && wdl != Loss {
+		return wdl, err
+	}
+
+	dtz, err := tb.probeDTZ(&pos)
+	if err != nil {
+		return Draw, err
+	}
+	switch {
+	case dtz > 0 && dtz+pos.HalfmoveCnt > 100:
+		return CursedWin, nil
+	// The checkmate ends the game before the draw can be claimed.
+	case dtz < -1 && -dtz+pos.HalfmoveCnt > 100:
+		return BlessedLoss, nil
+	}
+	return wdl, nil
+}
+
+// ProbeDTZ returns the distance to the next capture or pawn move in plies.
+// Positive values mean the active color wins and negative values mean it
+// loses:
+//   - n < -100: loss, but a draw under the 50-move rule;
+//   - -100 <= n <= -1: loss in n plies, -1 means the active color is
+//     checkmated;
+//   - 0: draw;
+//   - 1 <= n <= 100: win in n plies;
+//   - 100 < n: win, but a draw under the 50-move rule.
+//
+// The distance doesn't depend on [chego.Position.HalfmoveCnt], so the ranges
+// above hold for the zero counter.  Otherwise, the win is kept under the
+// 50-move rule only if n plus the counter doesn't exceed 100, and the loss only
+// if -n plus the counter doesn't exceed 100 or the active color is checkmated,
+// as [Tablebase.ProbeWDL] reports.
+// The value may be off by one ply, so the win is certain only if the sum
+// doesn't exceed 99, see [Tablebase.ProbeRoot].
+//
+// Both WDL and DTZ tables are required.
+func (tb *Tablebase) ProbeDTZ(p *chego.Position) (int, error) {
+	if p.CastlingRights != 0 {
+		return 0, ErrCastling
+	}
+
+	pos := *p
+	return tb.probeDTZ(&pos)
+}
+
+// RootMove is the legal move ranked by [Tablebase.ProbeRoot].
+type RootMove struct {
+	Move chego.Move
+	// DTZ of the position after the move, counted from the root position,
+	// see [Tablebase.ProbeDTZ].
+	DTZ int
+	// WDL is the result after the move, taking into account the halfmove
+	// counter of the root position.
+	WDL WDL
+	// Moves with the higher rank preserve the better result, or win faster.
+	// Moves with equal ranks are equally good.
+	Rank int
+}
+
+// ProbeRoot ranks the legal moves of the position using the DTZ tables.  The
+// moves are sorted by rank in descending order, so playing any of the first
+// moves with the equal rank preserves the result of the position, taking into
+// account [chego.Position.HalfmoveCnt].  Moves with the equal rank keep the
+// order of [chego.GenLegalMoves].
+//
+// Repetitions are not detected, since the position doesn't store the history.
+func (tb *Tablebase) ProbeRoot(p *chego.Position) ([]RootMove, error) {
+	if p.CastlingRights != 0 {
+		return nil, ErrCastling
+	}
+
+	pos := *p
+	cnt50 := pos.HalfmoveCnt
+	// Ranks of the won and lost moves beyond the bound are draws under the
+	// 50-move rule.
+	bound := maxDTZ/2 - 100
+
+	var l chego.MoveList
+	chego.GenLegalMoves(pos, &l)
+	moves := make([]RootMove, 0, l.Len)
+
+	for _, m := range l.Moves[:l.Len] {
+		u := pos.MakeMove(m)
+
+		var dtz int
+		var err error
+		switch {
+		case pos.HalfmoveCnt == 0:
+			// The DTZ of the capture or pawn move follows from the WDL.
+			var wdl WDL
+			wdl, _, err = tb.search(&pos, false)
+			dtz = dtzBeforeZeroing(-wdl)
+		case pos.HalfmoveCnt > 99 && (!inCheck(&pos) || hasMoves(&pos)):
+			// Draw under the 50-move rule.
+		default:
+			dtz, err = tb.probeDTZ(&pos)
+			dtz = -dtz
+			dtz += sign(dtz)
+		}
+
+		// Checkmating moves must have the DTZ of 1.
+		if dtz == 2 && inCheck(&pos) && !hasMoves(&pos) {
+			dtz = 1
+		}
+
+		pos.UnmakeMove(m, u)
+		if err != nil {
+			return nil, err
+		}
+
+		// Certain wins are ranked by DTZ.  Wins and losses which are draws
+		// under the 50-move rule are ranked by the sum of DTZ and the
+		// halfmove counter.
+		rm := RootMove{Move: m, DTZ: dtz}
+		switch {
+		case dtz > 0 && dtz+cnt50 <= 99:
+			rm.Rank = maxDTZ - dtz
+		case dtz > 0:
+			rm.Rank = maxDTZ/2 - (dtz + cnt50)
+		case dtz < 0 && -dtz*2+cnt50 < 100:
+			rm.Rank = -maxDTZ - dtz
+		case dtz < 0:
+			rm.Rank = -maxDTZ/2 + (-dtz + cnt50)
+		}
+
+		switch {
+		case rm.Rank >= bound:
+			rm.WDL = Win
+		case rm.Rank > 0:
+			rm.WDL = CursedWin
+		case rm.Rank == 0:
+			rm.WDL = Draw
+		case rm.Rank > -bound:
+			rm.WDL = BlessedLoss
+		default:
+			rm.WDL = Loss
+		}
+		moves = append(moves, rm)
+	}
+
+	slices.SortStableFunc(moves, func(a, b RootMove) int {
+		return b.Rank - a.Rank
+	})
+	return moves, nil
+}
+
+// search returns the result of the position.  The tables store "don't care"
+// values for the positions where the best move is a capture, so captures must
+// be searched as well.  If zeroing is true, pawn moves are searched too, since
+// the DTZ tables don't store the values for them.
+//
+// Returns stateZeroingBestMove if the best move is a capture or a pawn move.
+func (tb *Tablebase) search(p *chego.Position, zeroing bool) (WDL, probeState, error) {
+	var l chego.MoveList
+	chego.GenLegalMoves(*p, &l)
+
+	best := Loss
+	cnt := 0
+	for _, m := range l.Moves[:l.Len] {
+		if !isCapture(p, m) && (!zeroing || !isPawnMove(p, m)) {
+			continue
+		}
+		cnt++
+
+		u := p.MakeMove(m)
+		wdl, _, err := tb.search(p, false)
+		p.UnmakeMove(m, u)
+		if err != nil {
+			return Draw, stateOK, err
+		}
+
+		if -wdl > best {
+			best = -wdl
+			if best == Win {
+				return best, stateZeroingBestMove, nil
+			}
+		}
+	}
+
+	// The table is not probed if all legal moves have been searched, since
+	// the stored value may be wrong, e.g. the tables ignore en passant.
+	noMoreMoves := cnt > 0 && cnt == int(l.Len)
+
+	wdl := best
+	if !noMoreMoves {
+		v, _, err := tb.probeTable(p, false, Draw)
+		if err != nil {
+			return Draw, stateOK, err
+		}
+		wdl = WDL(v)
+	}
+
+	if best >= wdl {
+		if best > Draw || noMoreMoves {
+			return best, stateZeroingBestMove, nil
+		}
+		return best, stateOK, nil
+	}
+	return wdl, stateOK, nil
+}
+
+// probeDTZ implements [Tablebase.ProbeDTZ].  The position is restored after
+// the call.
+func (tb *Tablebase) probeDTZ(p *chego.Position) (int, error) {
+	wdl, state, err := tb.search(p, true)
+	if err != nil || wdl == Draw {
+		return 0, err
+	}
+	if state == stateZeroingBestMove {
+		return dtzBeforeZeroing(wdl), nil
+	}
+
+	dtz, state, err := tb.probeTable(p, true, wdl)
+	if err != nil {
+		return 0, err
+	}
+	if state != stateChangeSTM {
+		if wdl == CursedWin || wdl == BlessedLoss {
+			dtz += 100
+		}
+		return dtz * sign(int(wdl)), nil
+	}
+
+	// The table stores the positions of the other side to move, so find the
+	// best move with the 1-ply search.
+	var l chego.MoveList
+	chego.GenLegalMoves(*p, &l)
+
+	minDTZ := 0xFFFF
+	for _, m := range l.Moves[:l.Len] {
+		zeroing := isCapture(p, m) || isPawnMove(p, m)
+		u := p.MakeMove(m)
+
+		// The DTZ of the zeroing move follows from the result after it.
+		if zeroing {
+			var w WDL
+			w, _, err = tb.search(p, false)
+			dtz = -dtzBeforeZeroing(w)
+		} else {
+			dtz, err = tb.probeDTZ(p)
+			dtz = -dtz
+		}
+
+		if dtz == 1 && inCheck(p) && !hasMoves(p) {
+			minDTZ = 1
+		}
+		if !zeroing {
+			dtz += sign(dtz)
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+
+		p.UnmakeMove(m, u)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The active color is checkmated.
+	if minDTZ == 0xFFFF {
+		return -1, nil
+	}
+	return minDTZ, nil
+}
+
+// probeTable returns the value stored for the position in the WDL or DTZ
+// table.  wdl is the result of the position, which is required to decode the
+// DTZ values.
+func (tb *Tablebase) probeTable(p *chego.Position, dtz bool, wdl WDL) (int, probeState, error) {
+	// Bare kings.
+	if bits.OnesCount64(p.Bitboards[14]) == 2 {
+		return 0, stateOK, nil
+	}
+
+	tables := tb.wdl
+	if dtz {
+		tables = tb.dtz
+	}
+	t, ok := tables[materialKey(p)]
+	if !ok {
+		return 0, stateOK, fmt.Errorf("%w: %s", ErrMissingTable, materialName(p))
+	}
+	return t.probe(p, wdl)
+}
+
+// dtzBeforeZeroing returns the DTZ of the position in which the capture or the
+// pawn move leads to the specified result.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+// materialKey returns the key of the material on the board.  Unlike
+// [chego.Position.ZobristKey], it only depends on the number of pieces of each
+// type, so all positions of the table share the key.
+func materialKey(p *chego.Position) uint64 {
+	var cnt [12]int
+	for i := range cnt {
+		cnt[i] = bits.OnesCount64(p.Bitboards[i])
+	}
+	return countsKey(cnt, false)
+}
+
+// countsKey packs the number of pieces of each type into the key.  If swap is
+// true, the colors are swapped.
+func countsKey(cnt [12]int, swap bool) (key uint64) {
+	for p, n := range cnt {
+		if swap {
+			p ^= 1
+		}
+		key |= uint64(n) << (4 * p)
+	}
+	return key
+}
+
+// materialName returns the name of the table for the material on the board,
+// e.g. KRPvKN.
+func materialName(p *chego.Position) string {
+	var b strings.Builder
+	for c := range 2 {
+		if c == 1 {
+			b.WriteByte('v')
+		}
+		for i, letter := range "KQRBNP" {
+			piece := chego.WKing - 2*i + c
+			b.WriteString(strings.Repeat(string(letter),
+				bits.OnesCount64(p.Bitboards[piece])))
+		}
+	}
+	return b.String()
+}
+
+// isCapture reports whether the move captures a piece, including en passant.
+func isCapture(p *chego.Position, m chego.Move) bool {
+	return m.Type() == chego.MoveEnPassant ||
+		m.Type() != chego.MoveCastling && p.Bitboards[13-p.ActiveColor]&(1<<m.To()) != 0
+}
+
+// isPawnMove reports whether the move is made by a pawn.
+func isPawnMove(p *chego.Position, m chego.Move) bool {
+	return p.Bitboards[chego.WPawn+p.ActiveColor]&(1<<m.From()) != 0
+}
+
+// inCheck reports whether the king of the active color is in check.
+func inCheck(p *chego.Position) bool {
+	return chego.GenChecksCounter(p.Bitboards, 1^p.ActiveColor) > 0
+}
+
+// hasMoves reports whether the active color has legal moves.
+func hasMoves(p *chego.Position) bool {
+	var l chego.MoveList
+	chego.GenLegalMoves(*p, &l)
+	return l.Len > 0
+}
+
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
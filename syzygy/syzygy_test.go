@@ -0,0 +1,441 @@
+package syzygy
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/treepeck/chego"
+)
+
+// The synthetic KQvK tables cover the error handling and the decoding of the
+// hand-made data.  The WDL table stores a win for every position with white to
+// move, except for drawFEN, which is stored as a draw, and a loss for every
+// position with black to move.  The DTZ table stores the win in 5 moves for
+// every position with white to move.  The real tables are tested by
+// TestRealTables.
+const drawFEN = "7k/8/8/8/8/8/8/KQ6 w - - 0 1"
+
+// kqkTable returns the table with the pieces in the order used by the
+// synthetic files.
+func kqkTable(dtz bool) *table {
+	t, _ := newTable("", "KQvK", dtz)
+	for i := range 2 {
+		d := t.get(i, 0)
+		d.pieces = [maxPieces]int{6, 5, 14}
+		t.setGroups(d, [2]int{0, 0xF}, 0)
+	}
+	return t
+}
+
+// writeTables writes the synthetic tables into a temporary directory.
+func writeTables(t *testing.T) string {
+	dir := t.TempDir()
+
+	const size = 31332
+	_, drawIdx, _ := kqkTable(false).encode(chego.ParseFen(drawFEN), false, 0)
+
+	// Header: flags, the group order, the pieces and the word alignment.
+	wdl := append(wdlMagic[:], 1, 0, 0x66, 0x55, 0xEE, 0)
+	// White to move: a single block with one bit per value, symbol 0 is the
+	// win and symbol 1 is the draw.
+	wdl = append(wdl, 0, 12, 15, 0, 1, 0, 0, 0, 1, 1, 0, 0, 2, 0)
+	wdl = append(wdl, 4, 0xF0, 0xFF, 2, 0xF0, 0xFF)
+	// Black to move: all positions are lost.
+	wdl = append(wdl, flagSingleValue, 0)
+	// The sparse index points to the middle of the span.
+	wdl = binary.LittleEndian.AppendUint32(wdl, 0)
+	wdl = binary.LittleEndian.AppendUint16(wdl, 1<<14)
+	wdl = binary.LittleEndian.AppendUint16(wdl, size-1)
+	for len(wdl)%64 != 0 {
+		wdl = append(wdl, 0)
+	}
+	block := make([]byte, 1<<12)
+	block[drawIdx/8] |= 0x80 >> (drawIdx % 8)
+	wdl = append(wdl, block...)
+
+	dtz := append(dtzMagic[:], 1, 0, 0x66, 0x55, 0xEE, 0, flagSingleValue, 5)
+
+	for name, data := range map[string][]byte{
+		"KQvK.rtbw": wdl,
+		"KQvK.rtbz": dtz,
+		"KRvK.txt":  wdl,
+		"KRvK.rtbw": []byte("not a table"),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestProbeWDL(t *testing.T) {
+	tb, err := Open(writeTables(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+	if tb.MaxPieces() != 3 {
+		t.Fatalf("expected 3 pieces, got %d", tb.MaxPieces())
+	}
+
+	cases := []struct {
+		fen      string
+		expected WDL
+		err      error
+	}{
+		{drawFEN, Draw, nil},
+		// Mirrored and color flipped positions share the index.
+		{"6QK/8/8/8/8/8/8/k7 w - - 0 1", Draw, nil},
+		{"kq6/8/8/8/8/8/8/7K b - - 0 1", Draw, nil},
+		{"7k/8/8/8/8/8/8/K1Q5 w - - 0 1", Win, nil},
+		{"7k/8/8/8/8/8/8/K1Q5 b - - 0 1", Loss, nil},
+		{"7K/8/8/8/8/8/8/k1q5 w - - 0 1", Loss, nil},
+		// The halfmove counter leaves too few moves to win in 11 plies.
+		{"7k/8/8/8/8/8/8/K1Q5 w - - 89 100", Win, nil},
+		{"7k/8/8/8/8/8/8/K1Q5 w - - 90 100", CursedWin, nil},
+		{"7k/8/8/8/8/8/8/K1Q5 b - - 88 100", Loss, nil},
+		{"7k/8/8/8/8/8/8/K1Q5 b - - 89 100", BlessedLoss, nil},
+		// Black captures the hanging queen.
+		{"8/8/8/8/8/8/6kQ/K7 b - - 0 1", Draw, nil},
+		{"8/8/8/8/8/8/8/K5k1 w - - 0 1", Draw, nil},
+		{"8/8/8/8/8/8/8/KR5k w - - 0 1", Draw, ErrCorrupted},
+		{"8/8/8/8/8/8/8/KN5k w - - 0 1", Draw, ErrMissingTable},
+		{"8/8/8/8/8/8/8/R3K2k w Q - 0 1", Draw, ErrCastling},
+	}
+
+	for _, tc := range cases {
+		got, err := tb.ProbeWDL(chego.ParseFen(tc.fen))
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected error %v, got %v", tc.fen, tc.err, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%s: expected %s, got %s", tc.fen, tc.expected, got)
+		}
+	}
+}
+
+func TestProbeDTZ(t *testing.T) {
+	tb, err := Open(writeTables(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+
+	cases := []struct {
+		fen      string
+		expected int
+	}{
+		{drawFEN, 0},
+		{"7k/8/8/8/8/8/8/K1Q5 w - - 0 1", 11},
+		// The table stores white to move, so black moves are searched.
+		{"7k/8/8/8/8/8/8/K1Q5 b - - 0 1", -12},
+		{"8/8/8/8/8/8/6kQ/K7 b - - 0 1", 0},
+	}
+
+	for _, tc := range cases {
+		got, err := tb.ProbeDTZ(chego.ParseFen(tc.fen))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.fen, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%s: expected %d, got %d", tc.fen, tc.expected, got)
+		}
+	}
+}
+
+func TestProbeRoot(t *testing.T) {
+	tb, err := Open(writeTables(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tb.Close()
+
+	p := chego.ParseFen("k7/8/1K6/8/8/8/8/7Q w - - 0 1")
+	moves, err := tb.ProbeRoot(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var l chego.MoveList
+	chego.GenLegalMoves(*p, &l)
+	if len(moves) != int(l.Len) {
+		t.Fatalf("expected %d moves, got %d", l.Len, len(moves))
+	}
+
+	// Both Qb7# and Qh8# come first.
+	mates := []chego.Move{
+		chego.NewMove(chego.SB7, chego.SH1, chego.MoveNormal),
+		chego.NewMove(chego.SH8, chego.SH1, chego.MoveNormal),
+	}
+	for i, m := range mates {
+		if moves[i].Move != m || moves[i].DTZ != 1 || moves[i].WDL != Win {
+			t.Fatalf("expected mate %s, got %+v", m.UCI(), moves[i])
+		}
+	}
+	for i := 1; i < len(moves); i++ {
+		if moves[i].Rank > moves[i-1].Rank {
+			t.Fatalf("moves are not sorted: %+v", moves)
+		}
+	}
+
+	// Only the fastest wins preserve the result under the 50-move rule.
+	p = chego.ParseFen("k7/8/1K6/8/8/8/8/7Q w - - 97 100")
+	moves, err = tb.ProbeRoot(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range moves {
+		if m.DTZ == 0 && m.WDL != Draw ||
+			m.DTZ > 0 && m.DTZ+p.HalfmoveCnt <= 99 && m.WDL != Win ||
+			m.DTZ+p.HalfmoveCnt > 99 && m.WDL != CursedWin {
+			t.Fatalf("unexpected result: %+v", m)
+		}
+	}
+}
+
+// realTables lists the real tables required by the tests, see
+// testdata/README.md.
+var realTables = []string{"KQvK", "KRvK", "KPvK", "KPvKP"}
+
+// openReal opens the real tables stored in testdata.
+func openReal(t *testing.T) *Tablebase {
+	t.Helper()
+
+	for _, name := range realTables {
+		for _, ext := range []string{".rtbw", ".rtbz"} {
+			path := filepath.Join("testdata", name+ext)
+			if _, err := os.Stat(path); err != nil {
+				t.Skipf("real table is missing: %v", err)
+			}
+		}
+	}
+
+	tb, err := Open("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tb.Close() })
+	return tb
+}
+
+func TestRealTables(t *testing.T) {
+	tb := openReal(t)
+
+	cases := []struct {
+		fen string
+		wdl WDL
+		// DTZ is only checked for the mates and the zeroing moves, where it
+		// cannot be off by one.
+		dtz      int
+		checkDTZ bool
+	}{
+		// Qa8# and Rh8#.
+		{"7k/8/6K1/8/8/8/8/Q7 w - - 0 1", Win, 1, true},
+		{"7k/8/6K1/8/8/8/8/Q7 b - - 0 1", Loss, -2, true},
+		{"k7/8/1K6/8/8/8/8/7R w - - 0 1", Win, 1, true},
+		{"k7/8/1K6/8/8/8/8/7R b - - 0 1", Loss, -2, true},
+		// Black captures the hanging rook.
+		{"8/8/8/8/8/8/6kR/K7 b - - 0 1", Draw, 0, true},
+		// The king on the sixth rank in front of the pawn wins regardless of
+		// the side to move.
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", Win, 0, false},
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", Loss, 0, false},
+		{"4k3/4P3/4K3/8/8/8/8/8 w - - 0 1", Win, 0, false},
+		// Stalemate.
+		{"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", Draw, 0, true},
+		// Rook pawns are drawn with the king in the corner.
+		{"7k/8/6K1/7P/8/8/8/8 w - - 0 1", Draw, 0, true},
+		{"k7/8/1K6/P7/8/8/8/8 b - - 0 1", Draw, 0, true},
+		// c8=Q#.
+		{"k7/2P5/1K6/8/8/8/7p/8 w - - 0 1", Win, 0, false},
+		// Only the en passant capture stops the white pawn, and the black
+		// pawn queens first.
+		{"K7/8/8/8/3Pp3/8/8/7k b - d3 0 1", Win, 1, true},
+	}
+
+	for _, tc := range cases {
+		p := chego.ParseFen(tc.fen)
+
+		wdl, err := tb.ProbeWDL(p)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.fen, err)
+		}
+		if wdl != tc.wdl {
+			t.Fatalf("%s: expected %s, got %s", tc.fen, tc.wdl, wdl)
+		}
+
+		if !tc.checkDTZ {
+			continue
+		}
+		dtz, err := tb.ProbeDTZ(p)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.fen, err)
+		}
+		if dtz != tc.dtz {
+			t.Fatalf("%s: expected DTZ %d, got %d", tc.fen, tc.dtz, dtz)
+		}
+	}
+}
+
+func TestRealTablesMinimax(t *testing.T) {
+	tb := openReal(t)
+	r := rand.New(rand.NewPCG(1, 2))
+
+	// The result of each position must follow from the results after the
+	// legal moves.  The pawnless tables only lead to themselves and bare
+	// kings.
+	for _, pieces := range []string{"KQk", "KRk"} {
+		for range 500 {
+			p := randomPosition(r, pieces)
+			wdl, err := tb.ProbeWDL(p)
+			if err != nil {
+				t.Fatalf("%s: %v", chego.SerializeFen(p), err)
+			}
+
+			var l chego.MoveList
+			chego.GenLegalMoves(*p, &l)
+
+			best := Loss
+			// Stalemate.
+			if l.Len == 0 && !inCheck(p) {
+				best = Draw
+			}
+			for _, m := range l.Moves[:l.Len] {
+				child := *p
+				child.MakeMove(m)
+				child.HalfmoveCnt = 0
+
+				w, err := tb.ProbeWDL(&child)
+				if err != nil {
+					t.Fatalf("%s: %v", chego.SerializeFen(&child), err)
+				}
+				best = max(best, -w)
+			}
+			if wdl != best {
+				t.Fatalf("%s: expected %s, got %s", chego.SerializeFen(p), best, wdl)
+			}
+
+			dtz, err := tb.ProbeDTZ(p)
+			if err != nil {
+				t.Fatalf("%s: %v", chego.SerializeFen(p), err)
+			}
+			if sign(dtz) != sign(int(wdl)) {
+				t.Fatalf("%s: DTZ %d contradicts %s", chego.SerializeFen(p), dtz, wdl)
+			}
+		}
+	}
+}
+
+// randomPosition returns the random legal position with the specified pieces.
+func randomPosition(r *rand.Rand, pieces string) *chego.Position {
+	for {
+		var board [64]byte
+		for i := range pieces {
+			s := r.IntN(64)
+			for board[s] != 0 {
+				s = r.IntN(64)
+			}
+			board[s] = pieces[i]
+		}
+
+		var b strings.Builder
+		for rank := 7; rank >= 0; rank-- {
+			empty := 0
+			for file := range 8 {
+				c := board[8*rank+file]
+				if c == 0 {
+					empty++
+					continue
+				}
+				if empty > 0 {
+					b.WriteByte(byte('0' + empty))
+					empty = 0
+				}
+				b.WriteByte(c)
+			}
+			if empty > 0 {
+				b.WriteByte(byte('0' + empty))
+			}
+			if rank > 0 {
+				b.WriteByte('/')
+			}
+		}
+
+		stm := " w"
+		if r.IntN(2) == 1 {
+			stm = " b"
+		}
+		p, err := chego.ParseFENStrict(b.String() + stm + " - - 0 1")
+		if err != nil {
+			continue
+		}
+		// The kings must not touch each other.
+		wk := strings.IndexByte(string(board[:]), 'K')
+		bk := strings.IndexByte(string(board[:]), 'k')
+		if kingDistance(wk, bk) > 1 {
+			return p
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	// The index must be within the table for every placement and must not
+	// change when the board is mirrored.
+	kqk := kqkTable(false)
+	kpk, _ := newTable("", "KPvK", false)
+	for f := range 4 {
+		d := kpk.get(0, f)
+		d.pieces = [maxPieces]int{1, 6, 14}
+		kpk.setGroups(d, [2]int{0, 0xF}, f)
+	}
+
+	for s1 := range 64 {
+		for s2 := range 64 {
+			for s3 := range 64 {
+				if s1 == s2 || s1 == s3 || s2 == s3 || kingDistance(s1, s3) <= 1 {
+					continue
+				}
+
+				var p, mirrored chego.Position
+				for _, pc := range []struct {
+					piece chego.Piece
+					s     int
+				}{{chego.WKing, s1}, {chego.WQueen, s2}, {chego.BKing, s3}} {
+					p.Bitboards[pc.piece] |= 1 << pc.s
+					p.Bitboards[14] |= 1 << pc.s
+					mirrored.Bitboards[pc.piece] |= 1 << (pc.s ^ 7)
+					mirrored.Bitboards[14] |= 1 << (pc.s ^ 7)
+				}
+				testEncode(t, kqk, &p, &mirrored)
+
+				if s2 < 8 || s2 >= 56 {
+					continue
+				}
+				p.Bitboards[chego.WPawn] = p.Bitboards[chego.WQueen]
+				p.Bitboards[chego.WQueen] = 0
+				mirrored.Bitboards[chego.WPawn] = mirrored.Bitboards[chego.WQueen]
+				mirrored.Bitboards[chego.WQueen] = 0
+				testEncode(t, kpk, &p, &mirrored)
+			}
+		}
+	}
+}
+
+func testEncode(t *testing.T, tbl *table, p, mirrored *chego.Position) {
+	t.Helper()
+
+	p.Refresh()
+	mirrored.Refresh()
+
+	d, idx, file := tbl.encode(p, false, 0)
+	_, mirroredIdx, mirroredFile := tbl.encode(mirrored, false, 0)
+	if idx >= d.size() || idx != mirroredIdx || file != mirroredFile {
+		t.Fatalf("%s: unexpected index %d, mirrored %d, size %d",
+			chego.SerializeBitboards(p.Bitboards), idx, mirroredIdx, d.size())
+	}
+}
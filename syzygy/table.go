@@ -0,0 +1,664 @@
+// table.go implements the parsing and decompression of the table files.
+
+package syzygy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/treepeck/chego"
+)
+
+// Flags of the pairs data.  All but the last one are only used in the DTZ
+// tables.
+const (
+	// flagSTM is set if the DTZ table stores the positions with black to move.
+	flagSTM = 1 << iota
+	// flagMapped is set if the DTZ values are remapped, see [table.mapScore].
+	flagMapped
+	// flagWinPlies and flagLossPlies are set if the DTZ values of the won
+	// and lost positions are stored in plies rather than moves.
+	flagWinPlies
+	flagLossPlies
+	// flagWide is set if the DTZ map stores 16-bit values.
+	flagWide
+	flagSingleValue = 128
+)
+
+// Magic numbers at the start of the table files.
+var (
+	wdlMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+	dtzMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// pairsData stores the information required to decompress the values of one
+// subtable.  The tables store separate subtables for each side to move, and the
+// tables with pawns also for each file of the leading pawn.
+//
+// Values are compressed with the Recursive Pairing, which replaces the most
+// frequent adjacent pair of symbols with a new symbol, and the resulting
+// symbols are encoded with the canonical Huffman code.
+type pairsData struct {
+	flags     int
+	maxSymLen int
+	// minSymLen stores the value itself if flagSingleValue is set.
+	minSymLen int
+	numBlocks int
+	blockSize uint64
+	// There is a sparse index entry for every span values.
+	span            uint64
+	sparseIndexSize int
+	blockLengthSize int
+	// Offsets of the data in the file.  lowestSym[l] is the lowest symbol of
+	// length l, btree[s] stores the left and right symbols which expand s,
+	// blockLength[b] is the number of values in block b minus one, and
+	// sparseIndex[k] stores the block and the offset of the value with index
+	// k*span + span/2.
+	lowestSym   int
+	btree       int
+	blockLength int
+	sparseIndex int
+	data        int
+	// base64[l] is the lowest symbol of length l + minSymLen padded to 64
+	// bits.
+	base64 []uint64
+	// symlen[s] is the number of values represented by symbol s minus one.
+	symlen []int
+	// Pieces in the order of their encoding.  Equal pieces form the groups.
+	pieces   [maxPieces]int
+	groupLen [maxPieces + 1]int
+	// groupIdx[i] is the multiplier of the group index.  The element after
+	// the last group stores the size of the subtable.
+	groupIdx [maxPieces + 1]uint64
+	// Offsets of the DTZ map for the won, lost, cursed won and blessed lost
+	// positions.
+	mapIdx [4]int
+}
+
+// table represents a single WDL or DTZ table file.  The file is read on the
+// first probe.
+type table struct {
+	path string
+	dtz  bool
+	// Material keys of the table with white and black being the stronger
+	// side.  Equal if both sides have the same pieces.
+	key, key2       uint64
+	pieceCnt        int
+	hasPawns        bool
+	hasUniquePieces bool
+	// Number of pawns of the leading color and of the other color.
+	pawnCnt [2]int
+
+	once sync.Once
+	err  error
+	data []byte
+	// Offset of the DTZ map.
+	dtzMap int
+	// items[stm][file] stores the subtables.
+	items [2][4]pairsData
+}
+
+// newTable creates the table for the file with the name such as KRPvKN.
+// Returns false if the name is invalid.
+func newTable(path, name string, dtz bool) (*table, bool) {
+	white, black, ok := strings.Cut(name, "v")
+	if !ok || !isSide(white) || !isSide(black) ||
+		len(white)+len(black) > maxPieces {
+		return nil, false
+	}
+
+	var cnt [12]int
+	for _, c := range white {
+		cnt[pieceIndex(c)]++
+	}
+	for _, c := range black {
+		cnt[pieceIndex(c)+1]++
+	}
+
+	t := &table{
+		path:     path,
+		dtz:      dtz,
+		key:      countsKey(cnt, false),
+		key2:     countsKey(cnt, true),
+		pieceCnt: len(white) + len(black),
+		hasPawns: cnt[chego.WPawn]+cnt[chego.BPawn] > 0,
+	}
+	for p := chego.WPawn; p < chego.WKing; p++ {
+		if cnt[p] == 1 {
+			t.hasUniquePieces = true
+		}
+	}
+
+	// The leading color is the one with less pawns, since it leads to the
+	// better compression.
+	wp, bp := cnt[chego.WPawn], cnt[chego.BPawn]
+	if bp == 0 || wp > 0 && bp >= wp {
+		t.pawnCnt = [2]int{wp, bp}
+	} else {
+		t.pawnCnt = [2]int{bp, wp}
+	}
+	return t, true
+}
+
+// isSide reports whether s is a valid set of pieces of one side.
+func isSide(s string) bool {
+	if len(s) == 0 || s[0] != 'K' || strings.Count(s, "K") != 1 {
+		return false
+	}
+	return strings.Trim(s, "KQRBNP") == ""
+}
+
+// pieceIndex returns the white chego piece for the letter.
+func pieceIndex(c rune) chego.Piece {
+	return strings.IndexRune("PNBRQK", c) * 2
+}
+
+// get returns the subtable for the side to move and the file of the leading
+// pawn.  DTZ tables store a single side to move.
+func (t *table) get(stm, file int) *pairsData {
+	if t.dtz {
+		stm = 0
+	}
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.items[stm][file]
+}
+
+// load reads and parses the table file.  Safe for concurrent use.
+func (t *table) load() error {
+	t.once.Do(func() {
+		t.err = t.init()
+		if t.err != nil {
+			t.err = fmt.Errorf("%s: %w", t.path, t.err)
+		}
+	})
+	return t.err
+}
+
+// init maps the table file into memory, parses its header and sets up the
+// subtables.
+func (t *table) init() error {
+	data, err := mapFile(t.path)
+	if err != nil {
+		return err
+	}
+	t.data = data
+
+	magic := wdlMagic
+	if t.dtz {
+		magic = dtzMagic
+	}
+	if len(data) < 6 || [4]byte(data[:4]) != magic {
+		return ErrCorrupted
+	}
+
+	const (
+		split    = 1
+		hasPawns = 2
+	)
+	off := 4
+	if t.hasPawns != (data[off]&hasPawns != 0) ||
+		(t.key != t.key2) != (data[off]&split != 0) {
+		return ErrCorrupted
+	}
+	off++
+
+	sides := 1
+	if !t.dtz && t.key != t.key2 {
+		sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	// Pawns on both sides.
+	pp := t.hasPawns && t.pawnCnt[1] > 0
+
+	for f := 0; f <= maxFile; f++ {
+		if off+2+t.pieceCnt > len(data) {
+			return ErrCorrupted
+		}
+
+		// order[stm] stores the positions of the leading group and the
+		// remaining pawns in the sequence of the encoded groups.
+		order := [2][2]int{
+			{int(data[off] & 0xF), 0xF},
+			{int(data[off] >> 4), 0xF},
+		}
+		if pp {
+			order[0][1] = int(data[off+1] & 0xF)
+			order[1][1] = int(data[off+1] >> 4)
+			off++
+		}
+		off++
+
+		for k := range t.pieceCnt {
+			for i := range sides {
+				p := int(data[off] & 0xF)
+				if i == 1 {
+					p = int(data[off] >> 4)
+				}
+				t.get(i, f).pieces[k] = p
+			}
+			off++
+		}
+
+		for i := range sides {
+			if err := t.setGroups(t.get(i, f), order[i], f); err != nil {
+				return err
+			}
+		}
+	}
+	// Word alignment.
+	off += off & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := range sides {
+			if off, err = t.setSizes(t.get(i, f), off); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.dtz {
+		if off, err = t.setDTZMap(off, maxFile); err != nil {
+			return err
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := range sides {
+			d := t.get(i, f)
+			d.sparseIndex = off
+			off += d.sparseIndexSize * 6
+		}
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := range sides {
+			d := t.get(i, f)
+			d.blockLength = off
+			off += d.blockLengthSize * 2
+		}
+	}
+	if off > len(data) {
+		return ErrCorrupted
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		for i := range sides {
+			d := t.get(i, f)
+			// 64-byte alignment.
+			off = (off + 0x3F) &^ 0x3F
+			d.data = off
+			off += d.numBlocks * int(d.blockSize)
+			// The single value subtables have no data, so the alignment
+			// may exceed the file size.
+			if d.numBlocks > 0 && off > len(data) {
+				return ErrCorrupted
+			}
+		}
+	}
+	return nil
+}
+
+// setGroups splits the pieces into the groups which are encoded together and
+// computes the multipliers of the group indices.
+//
+// A group consists of the equal pieces, except for the leading group of the
+// positions without pawns, which consists of three unique pieces, e.g. KRvKN
+// is encoded as KRK + N, or of the kings, e.g. KNNvK is encoded as KK + NN.
+func (t *table) setGroups(d *pairsData, order [2]int, f int) error {
+	firstLen := 2
+	if t.hasPawns {
+		firstLen = 0
+	} else if t.hasUniquePieces {
+		firstLen = 3
+	}
+
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieceCnt; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	// The groups are not necessarily encoded in the order of the pieces: the
+	// leading group is at order[0] and the remaining pawns, if any, are at
+	// order[1].
+	pp := t.hasPawns && t.pawnCnt[1] > 0
+	next := 1
+	free := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		free -= d.groupLen[1]
+	}
+
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			if t.hasPawns {
+				idx *= leadPawnsSize[d.groupLen[0]][f]
+			} else if t.hasUniquePieces {
+				idx *= 31332
+			} else {
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= binomial[d.groupLen[1]][48-d.groupLen[0]]
+		case k > maxPieces:
+			return ErrCorrupted
+		default:
+			d.groupIdx[next] = idx
+			idx *= binomial[d.groupLen[next]][free]
+			free -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+	return nil
+}
+
+// size returns the number of values in the subtable.
+func (d *pairsData) size() uint64 {
+	i := 0
+	for d.groupLen[i] != 0 {
+		i++
+	}
+	return d.groupIdx[i]
+}
+
+// setSizes parses the compression parameters of the subtable starting at the
+// offset off and returns the offset of the next subtable.
+func (t *table) setSizes(d *pairsData, off int) (int, error) {
+	data := t.data
+	if off+2 > len(data) {
+		return 0, ErrCorrupted
+	}
+
+	d.flags = int(data[off])
+	off++
+	if d.flags&flagSingleValue != 0 {
+		d.minSymLen = int(data[off])
+		return off + 1, nil
+	}
+
+	if off+10 > len(data) {
+		return 0, ErrCorrupted
+	}
+	tbSize := d.size()
+	d.blockSize = 1 << data[off]
+	d.span = 1 << data[off+1]
+	d.sparseIndexSize = int((tbSize + d.span - 1) / d.span)
+	padding := int(data[off+2])
+	d.numBlocks = int(binary.LittleEndian.Uint32(data[off+3:]))
+	// The padding ensures that the sparse index doesn't point out of range.
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(data[off+7])
+	d.minSymLen = int(data[off+8])
+	off += 9
+	d.lowestSym = off
+
+	if d.maxSymLen < d.minSymLen || d.minSymLen == 0 {
+		return 0, ErrCorrupted
+	}
+	n := d.maxSymLen - d.minSymLen + 1
+	if off+2*n+2 > len(data) {
+		return 0, ErrCorrupted
+	}
+
+	// In the canonical Huffman code longer symbols have lower values, so
+	// base64[l] >= base64[l+1].  For any symbol s of length l + minSymLen
+	// padded to 64 bits holds base64[l-1] > s >= base64[l].
+	d.base64 = make([]uint64, n)
+	for i := n - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(t.lowest(d, i)) -
+			uint64(t.lowest(d, i+1))) / 2
+	}
+	for i := range n {
+		d.base64[i] <<= 64 - i - d.minSymLen
+	}
+	off += 2 * n
+
+	symCnt := int(binary.LittleEndian.Uint16(data[off:]))
+	off += 2
+	d.btree = off
+	if off+3*symCnt > len(data) {
+		return 0, ErrCorrupted
+	}
+
+	for s := range symCnt {
+		r := t.right(d, s)
+		if r != 0xFFF && (r >= symCnt || t.left(d, s) >= symCnt) {
+			return 0, ErrCorrupted
+		}
+	}
+
+	d.symlen = make([]int, symCnt)
+	visited := make([]bool, symCnt)
+	for s := range symCnt {
+		if !visited[s] {
+			d.symlen[s] = t.setSymlen(d, s, visited)
+		}
+	}
+
+	return off + 3*symCnt + symCnt&1, nil
+}
+
+// setSymlen computes the number of values represented by the symbol by
+// expanding it into its left and right symbols until reaching the leaves.
+func (t *table) setSymlen(d *pairsData, s int, visited []bool) int {
+	// The tree is acyclic, so the symbol can be marked right away.
+	visited[s] = true
+
+	r := t.right(d, s)
+	if r == 0xFFF {
+		return 0
+	}
+	l := t.left(d, s)
+
+	if !visited[l] {
+		d.symlen[l] = t.setSymlen(d, l, visited)
+	}
+	if !visited[r] {
+		d.symlen[r] = t.setSymlen(d, r, visited)
+	}
+	return d.symlen[l] + d.symlen[r] + 1
+}
+
+// setDTZMap parses the DTZ map starting at the offset off and returns the
+// offset after it.
+func (t *table) setDTZMap(off, maxFile int) (int, error) {
+	t.dtzMap = off
+
+	for f := 0; f <= maxFile; f++ {
+		d := t.get(0, f)
+		if d.flags&flagMapped == 0 {
+			continue
+		}
+
+		if d.flags&flagWide != 0 {
+			off += off & 1
+			for i := range d.mapIdx {
+				if off+2 > len(t.data) {
+					return 0, ErrCorrupted
+				}
+				d.mapIdx[i] = (off-t.dtzMap)/2 + 1
+				off += 2*int(binary.LittleEndian.Uint16(t.data[off:])) + 2
+			}
+		} else {
+			for i := range d.mapIdx {
+				if off >= len(t.data) {
+					return 0, ErrCorrupted
+				}
+				d.mapIdx[i] = off - t.dtzMap + 1
+				off += int(t.data[off]) + 1
+			}
+		}
+	}
+	return off + off&1, nil
+}
+
+// lowest returns the lowest symbol of the length l + minSymLen.
+func (t *table) lowest(d *pairsData, l int) int {
+	return int(binary.LittleEndian.Uint16(t.data[d.lowestSym+2*l:]))
+}
+
+// left and right return the symbols which expand the symbol s.  The right
+// symbol of the leaves is 0xFFF and the left symbol stores the value.
+func (t *table) left(d *pairsData, s int) int {
+	b := t.data[d.btree+3*s:]
+	return int(b[1]&0xF)<<8 | int(b[0])
+}
+
+func (t *table) right(d *pairsData, s int) int {
+	b := t.data[d.btree+3*s:]
+	return int(b[2])<<4 | int(b[1]>>4)
+}
+
+// blockLen returns the number of values stored in the block minus one.
+func (t *table) blockLen(d *pairsData, block int) int {
+	return int(binary.LittleEndian.Uint16(t.data[d.blockLength+2*block:]))
+}
+
+// be32 reads the big endian number at the offset off.  Bytes after the end of
+// the file are read as zeros.
+func (t *table) be32(off int) uint32 {
+	var b [4]byte
+	if off < len(t.data) {
+		copy(b[:], t.data[off:])
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// decompress returns the value with the index idx.
+func (t *table) decompress(d *pairsData, idx uint64) int {
+	if d.flags&flagSingleValue != 0 {
+		return d.minSymLen
+	}
+
+	// Find the block which stores the value using the nearest sparse index
+	// entry.
+	k := d.sparseIndex + int(idx/d.span)*6
+	block := int(binary.LittleEndian.Uint32(t.data[k:]))
+	offset := int(binary.LittleEndian.Uint16(t.data[k+4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+
+	for offset < 0 {
+		block--
+		offset += t.blockLen(d, block) + 1
+	}
+	for offset > t.blockLen(d, block) {
+		offset -= t.blockLen(d, block) + 1
+		block++
+	}
+
+	// Read the symbols from the start of the block until the one which
+	// contains the value.
+	ptr := d.data + block*int(d.blockSize)
+	buf := uint64(t.be32(ptr))<<32 | uint64(t.be32(ptr+4))
+	ptr += 8
+	bufSize := 64
+
+	var sym int
+	for {
+		l := 0
+		for buf < d.base64[l] {
+			l++
+		}
+		// Symbols of the same length are consecutive.
+		sym = int((buf-d.base64[l])>>(64-l-d.minSymLen)) + t.lowest(d, l)
+
+		if offset < d.symlen[sym]+1 {
+			break
+		}
+		offset -= d.symlen[sym] + 1
+
+		l += d.minSymLen
+		buf <<= l
+		bufSize -= l
+		if bufSize <= 32 {
+			bufSize += 32
+			buf |= uint64(t.be32(ptr)) << (64 - bufSize)
+			ptr += 4
+		}
+	}
+
+	// Expand the symbol until reaching the leaf with the value.
+	for d.symlen[sym] != 0 {
+		l := t.left(d, sym)
+		if offset < d.symlen[l]+1 {
+			sym = l
+		} else {
+			offset -= d.symlen[l] + 1
+			sym = t.right(d, sym)
+		}
+	}
+	return t.left(d, sym)
+}
+
+// probe returns the value stored for the position.  stateChangeSTM is
+// returned if the DTZ table doesn't store the positions with the active color
+// to move.
+func (t *table) probe(p *chego.Position, wdl WDL) (int, probeState, error) {
+	if err := t.load(); err != nil {
+		return 0, stateOK, err
+	}
+
+	// If both sides have the same pieces, the tables only store the positions
+	// with white to move.  Otherwise, they only store the positions with
+	// white being the stronger side.
+	flip := p.ActiveColor == chego.ColorBlack && t.key == t.key2 ||
+		materialKey(p) != t.key
+	stm := p.ActiveColor
+	if flip {
+		stm ^= 1
+	}
+
+	d, idx, file := t.encode(p, flip, stm)
+	if t.dtz && d.flags&flagSTM != stm && (t.key != t.key2 || t.hasPawns) {
+		return 0, stateChangeSTM, nil
+	}
+
+	value := t.decompress(d, idx)
+	if !t.dtz {
+		return value - 2, stateOK, nil
+	}
+	return t.mapScore(file, value, wdl), stateOK, nil
+}
+
+// mapScore converts the stored DTZ value into plies.  The values are sorted by
+// frequency for each WDL result, so the map restores the original values.
+func (t *table) mapScore(file, value int, wdl WDL) int {
+	d := t.get(0, file)
+
+	if d.flags&flagMapped != 0 {
+		// Index of the map for each WDL result.
+		i := d.mapIdx[[5]int{1, 3, 0, 2, 0}[wdl+2]] + value
+		if d.flags&flagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.data[t.dtzMap+2*i:]))
+		} else {
+			value = int(t.data[t.dtzMap+i])
+		}
+	}
+
+	if wdl == Win && d.flags&flagWinPlies == 0 ||
+		wdl == Loss && d.flags&flagLossPlies == 0 ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
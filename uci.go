@@ -0,0 +1,90 @@
+// uci.go implements serialization and parsing of moves in the long algebraic
+// notation used by the Universal Chess Interface.
+
+package chego
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidUCI is returned when the UCI move string is malformed.
+var ErrInvalidUCI = errors.New("invalid UCI move")
+
+// UCI converts the move into a long algebraic notation string.
+//
+// Examples: e2e4, e7e5, e1g1 (white short castling), e7e8q (for promotion).
+func (m Move) UCI() string {
+	var b strings.Builder
+	b.Grow(5)
+
+	b.WriteString(Square2String[m.From()])
+	b.WriteString(Square2String[m.To()])
+
+	if m.Type() == MovePromotion {
+		switch m.PromoPiece() {
+		case PromotionKnight:
+			b.WriteByte('n')
+		case PromotionBishop:
+			b.WriteByte('b')
+		case PromotionRook:
+			b.WriteByte('r')
+		case PromotionQueen:
+			b.WriteByte('q')
+		}
+	}
+
+	return b.String()
+}
+
+// ParseUCIMove converts the long algebraic notation string into the legal move
+// in the specified position.  The move type is not encoded in the string, so
+// it is inferred from the legal moves of the position.
+//
+// Returns [ErrInvalidUCI] if the string is malformed and [ErrIllegalMove] if the
+// move is not legal.
+func ParseUCIMove(s string, p *Position) (Move, error) {
+	if (len(s) != 4 && len(s) != 5) || !isFile(s[0]) || !isRank(s[1]) ||
+		!isFile(s[2]) || !isRank(s[3]) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidUCI, s)
+	}
+
+	from := int(s[0]-'a') + 8*int(s[1]-'1')
+	to := int(s[2]-'a') + 8*int(s[3]-'1')
+
+	promo := -1
+	if len(s) == 5 {
+		switch s[4] {
+		case 'n':
+			promo = PromotionKnight
+		case 'b':
+			promo = PromotionBishop
+		case 'r':
+			promo = PromotionRook
+		case 'q':
+			promo = PromotionQueen
+		default:
+			return 0, fmt.Errorf("%w: %q", ErrInvalidUCI, s)
+		}
+	}
+
+	var legal MoveList
+	GenLegalMoves(*p, &legal)
+
+	for i := range legal.Len {
+		m := legal.Moves[i]
+		if m.From() != from || m.To() != to {
+			continue
+		}
+		if m.Type() == MovePromotion && m.PromoPiece() != promo {
+			continue
+		}
+		if m.Type() != MovePromotion && promo != -1 {
+			continue
+		}
+		return m, nil
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrIllegalMove, s)
+}
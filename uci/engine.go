@@ -0,0 +1,321 @@
+// Package uci implements the client side of the Universal Chess Interface
+// protocol, which allows to drive external chess engines, such as Stockfish.
+//
+// See https://www.wbec-ridderkerk.nl/html/UCIProtocol.html.
+package uci
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/treepeck/chego"
+)
+
+var (
+	// ErrExited is returned when the engine process exits unexpectedly.
+	ErrExited = errors.New("engine exited")
+	// ErrTimeout is returned when the engine doesn't respond in time.
+	ErrTimeout = errors.New("engine timeout")
+	// ErrUnknownOption is returned when the engine doesn't have the option.
+	ErrUnknownOption = errors.New("unknown engine option")
+)
+
+// closeTimeout is the time given to the engine to exit after the quit command
+// before the process is killed.
+const closeTimeout = time.Second
+
+// Option represents the engine option reported during the handshake.
+type Option struct {
+	Name string
+	// Type is one of "check", "spin", "combo", "button" or "string".
+	Type    string
+	Default string
+	// Min and Max are only reported for the spin options.
+	Min, Max string
+	// Vars stores the predefined values of the combo options.
+	Vars []string
+}
+
+// Engine is the running engine process.  Methods of the Engine must not be
+// called concurrently, and no methods except [Engine.Close] may be called while
+// the search started by [Engine.Go] is running.
+type Engine struct {
+	// Name and Author are reported by the engine during the handshake.
+	Name   string
+	Author string
+	// Options stores the options supported by the engine.
+	Options []Option
+	// StopTimeout is the time given to the engine to report the best move
+	// after the stop command.  Defaults to one second.
+	StopTimeout time.Duration
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// lines receives the output of the engine line by line.  Closed when the
+	// engine closes its output.
+	lines chan string
+	// done is closed by [Engine.Close] to stop sending the output into lines.
+	done chan struct{}
+	// readDone is closed once the whole output of the engine has been read.
+	readDone  chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+	// Position set by the last [Engine.SetPosition] call, used to parse the
+	// moves reported by the engine.
+	pos chego.Position
+}
+
+// Start launches the engine executable with the specified arguments and
+// performs the UCI handshake.  The context limits the duration of the
+// handshake, the process itself keeps running until [Engine.Close] is called.
+func Start(ctx context.Context, name string, args ...string) (*Engine, error) {
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		StopTimeout: time.Second,
+		cmd:         cmd,
+		stdin:       stdin,
+		lines:       make(chan string, 64),
+		done:        make(chan struct{}),
+		readDone:    make(chan struct{}),
+		pos:         *chego.ParseFen(chego.InitialPos),
+	}
+	go e.readLines(stdout)
+
+	if err := e.handshake(ctx); err != nil {
+		close(e.done)
+		cmd.Process.Kill()
+		<-e.readDone
+		cmd.Wait()
+		return nil, err
+	}
+	return e, nil
+}
+
+// readLines sends the output of the engine into the lines channel.  Once the
+// done channel is closed, nobody reads the lines anymore, so the rest of the
+// output is discarded.  It is still read until the end, so the engine doesn't
+// block on writing before it exits.
+func (e *Engine) readLines(r io.Reader) {
+	defer close(e.readDone)
+	defer close(e.lines)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		select {
+		case e.lines <- scanner.Text():
+		case <-e.done:
+		}
+	}
+}
+
+// handshake sends the uci command and collects the engine identity and options
+// until the uciok response.
+func (e *Engine) handshake(ctx context.Context) error {
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uciok":
+			return nil
+		case "id":
+			if len(fields) < 3 {
+				continue
+			}
+			value := strings.Join(fields[2:], " ")
+			if fields[1] == "name" {
+				e.Name = value
+			} else if fields[1] == "author" {
+				e.Author = value
+			}
+		case "option":
+			e.Options = append(e.Options, parseOption(fields[1:]))
+		}
+	}
+}
+
+// parseOption parses the fields of the option command.  Names and values may
+// contain spaces, so each of them continues until the next keyword.
+func parseOption(fields []string) Option {
+	var o Option
+	var key string
+	var value []string
+
+	flush := func() {
+		v := strings.Join(value, " ")
+		switch key {
+		case "name":
+			o.Name = v
+		case "type":
+			o.Type = v
+		case "default":
+			o.Default = v
+		case "min":
+			o.Min = v
+		case "max":
+			o.Max = v
+		case "var":
+			o.Vars = append(o.Vars, v)
+		}
+		value = value[:0]
+	}
+
+	for _, f := range fields {
+		switch f {
+		case "name", "type", "default", "min", "max", "var":
+			flush()
+			key = f
+		default:
+			value = append(value, f)
+		}
+	}
+	flush()
+	return o
+}
+
+// SetOption sets the value of the engine option.  The value is ignored for the
+// button options.  Returns [ErrUnknownOption] if the engine hasn't reported
+// the option during the handshake.
+func (e *Engine) SetOption(name, value string) error {
+	for _, o := range e.Options {
+		if !strings.EqualFold(o.Name, name) {
+			continue
+		}
+		if o.Type == "button" {
+			return e.send("setoption name " + o.Name)
+		}
+		return e.send("setoption name " + o.Name + " value " + value)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownOption, name)
+}
+
+// IsReady waits until the engine is ready to accept new commands.  Should be
+// called after setting the options, since some of them take a while to apply.
+func (e *Engine) IsReady(ctx context.Context) error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	for {
+		line, err := e.readLine(ctx)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(line) == "readyok" {
+			return nil
+		}
+	}
+}
+
+// NewGame notifies the engine that the next position belongs to a different
+// game and waits until it is ready.
+func (e *Engine) NewGame(ctx context.Context) error {
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.IsReady(ctx)
+}
+
+// SetPosition sets the position to search.  The moves are played from the
+// start position and must be legal, so the engine can detect repetitions.
+// Chess960 positions require the engine option UCI_Chess960 to be set.
+func (e *Engine) SetPosition(start *chego.Position, moves []chego.Move) error {
+	var b strings.Builder
+	b.WriteString("position fen ")
+	b.WriteString(chego.SerializeFen(start))
+
+	p := *start
+	if len(moves) > 0 {
+		b.WriteString(" moves")
+	}
+	for _, m := range moves {
+		b.WriteString(" " + m.UCI())
+		p.MakeMove(m)
+	}
+
+	if err := e.send(b.String()); err != nil {
+		return err
+	}
+	e.pos = p
+	return nil
+}
+
+// Close sends the quit command and waits until the engine exits.  The process
+// is killed if it doesn't exit in time.  Subsequent calls return the result of
+// the first one.
+func (e *Engine) Close() error {
+	e.closeOnce.Do(func() { e.closeErr = e.close() })
+	return e.closeErr
+}
+
+func (e *Engine) close() error {
+	close(e.done)
+	e.send("quit")
+	e.stdin.Close()
+
+	// The output must be read completely before waiting for the process.
+	exited := make(chan error, 1)
+	go func() {
+		<-e.readDone
+		exited <- e.cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(closeTimeout):
+		e.cmd.Process.Kill()
+		<-exited
+		return ErrTimeout
+	}
+}
+
+// send writes the command into the engine input.
+func (e *Engine) send(cmd string) error {
+	if _, err := io.WriteString(e.stdin, cmd+"\n"); err != nil {
+		return fmt.Errorf("%w: %v", ErrExited, err)
+	}
+	return nil
+}
+
+// readLine returns the next line of the engine output.
+func (e *Engine) readLine(ctx context.Context) (string, error) {
+	select {
+	case line, ok := <-e.lines:
+		if !ok {
+			return "", ErrExited
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
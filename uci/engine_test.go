@@ -0,0 +1,208 @@
+package uci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/treepeck/chego"
+)
+
+func startFake(t *testing.T) *Engine {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := Start(ctx, "testdata/engine.sh")
+	if err != nil {
+		t.Fatalf("cannot start the engine: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+func TestHandshake(t *testing.T) {
+	e := startFake(t)
+
+	if e.Name != "Fake Engine 1.0" || e.Author != "chego" {
+		t.Fatalf("unexpected identity: %q by %q", e.Name, e.Author)
+	}
+
+	expected := []Option{
+		{Name: "Hash", Type: "spin", Default: "16", Min: "1", Max: "1024"},
+		{Name: "Skill Level", Type: "combo", Default: "Normal",
+			Vars: []string{"Easy", "Normal"}},
+		{Name: "Clear Hash", Type: "button"},
+	}
+	if len(e.Options) != len(expected) {
+		t.Fatalf("expected %d options, got %d", len(expected), len(e.Options))
+	}
+	for i, o := range e.Options {
+		exp := expected[i]
+		if o.Name != exp.Name || o.Type != exp.Type || o.Default != exp.Default ||
+			o.Min != exp.Min || o.Max != exp.Max || len(o.Vars) != len(exp.Vars) {
+			t.Fatalf("expected %+v, got %+v", exp, o)
+		}
+	}
+
+	if err := e.SetOption("hash", "64"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetOption("Threads", "2"); !errors.Is(err, ErrUnknownOption) {
+		t.Fatalf("expected error %v, got %v", ErrUnknownOption, err)
+	}
+	if err := e.NewGame(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGo(t *testing.T) {
+	e := startFake(t)
+
+	if err := e.SetPosition(chego.ParseFen(chego.InitialPos), nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err := e.Go(context.Background(), Limits{Depth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var infos []Info
+	for i := range s.Info {
+		infos = append(infos, i)
+	}
+	r, err := s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 info lines, got %d", len(infos))
+	}
+	if infos[0].Score.Value != 13 || infos[0].Score.Mate || infos[0].NPS != 20000 {
+		t.Fatalf("unexpected first info: %+v", infos[0])
+	}
+
+	e2e4 := chego.NewMove(chego.SE4, chego.SE2, chego.MoveNormal)
+	e7e5 := chego.NewMove(chego.SE5, chego.SE7, chego.MoveNormal)
+	if r.BestMove != e2e4 || r.Ponder != e7e5 {
+		t.Fatalf("expected e2e4 ponder e7e5, got %s ponder %s",
+			r.BestMove.UCI(), r.Ponder.UCI())
+	}
+	if !r.Info.Score.Mate || r.Info.Score.Value != 3 || !r.Info.Score.Lower ||
+		len(r.Info.PV) != 2 || r.Info.PV[1] != e7e5 {
+		t.Fatalf("unexpected last info: %+v", r.Info)
+	}
+}
+
+func TestGoCancel(t *testing.T) {
+	e := startFake(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := e.Go(ctx, Limits{Infinite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-s.Info
+	cancel()
+
+	r, err := s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BestMove != chego.NewMove(chego.SD4, chego.SD2, chego.MoveNormal) {
+		t.Fatalf("expected d2d4, got %s", r.BestMove.UCI())
+	}
+}
+
+func TestGoStopTimeout(t *testing.T) {
+	e := startFake(t)
+	e.StopTimeout = 50 * time.Millisecond
+
+	if err := e.send("setoption name Hang value true"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := e.Go(ctx, Limits{Infinite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if _, err := s.Wait(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected error %v, got %v", ErrTimeout, err)
+	}
+}
+
+func TestCloseUnreadOutput(t *testing.T) {
+	e := startFake(t)
+
+	// Nobody reads the responses, so they overflow the lines buffer.
+	for range 20 {
+		if err := e.send("uci"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-e.readDone:
+	case <-time.After(time.Second):
+		t.Fatal("the output is still being read after Close")
+	}
+}
+
+func TestLimitsString(t *testing.T) {
+	cases := []struct {
+		limits   Limits
+		expected string
+	}{
+		{Limits{}, "go"},
+		{Limits{Depth: 12}, "go depth 12"},
+		{Limits{MoveTime: 1500 * time.Millisecond}, "go movetime 1500"},
+		{Limits{WTime: time.Minute, BTime: time.Minute, WInc: time.Second,
+			BInc: time.Second, MovesToGo: 20},
+			"go wtime 60000 btime 60000 winc 1000 binc 1000 movestogo 20"},
+		{Limits{Infinite: true}, "go infinite"},
+	}
+
+	for _, tc := range cases {
+		if got := tc.limits.String(); got != tc.expected {
+			t.Fatalf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	p := chego.ParseFen(chego.InitialPos)
+
+	cases := []struct {
+		line string
+		err  error
+	}{
+		{"info depth 10 score cp -35 upperbound pv e2e4 e7e5 g1f3", nil},
+		{"info string hello world", nil},
+		{"info depth x", ErrInvalidInfo},
+		{"info score", ErrInvalidInfo},
+		{"bestmove e2e4", ErrInvalidInfo},
+		{"info depth 1 pv e2e5", chego.ErrIllegalMove},
+	}
+
+	for _, tc := range cases {
+		_, err := ParseInfo(tc.line, p)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected error %v, got %v", tc.line, tc.err, err)
+		}
+	}
+
+	i, _ := ParseInfo(cases[0].line, p)
+	if i.Depth != 10 || i.Score.Value != -35 || !i.Score.Upper || len(i.PV) != 3 {
+		t.Fatalf("unexpected info: %+v", i)
+	}
+}
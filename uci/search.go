@@ -0,0 +1,309 @@
+// search.go implements the go command and the parsing of the search output.
+
+package uci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/treepeck/chego"
+)
+
+// ErrInvalidInfo is returned when the info line cannot be parsed.
+var ErrInvalidInfo = errors.New("invalid info line")
+
+// Limits restricts the search.  Zero values are not sent to the engine.
+type Limits struct {
+	Depth    int
+	Nodes    uint64
+	MoveTime time.Duration
+	// Remaining time on the clocks and the increments per move.
+	WTime, BTime time.Duration
+	WInc, BInc   time.Duration
+	// MovesToGo is the number of moves until the next time control.
+	MovesToGo int
+	// Infinite search runs until the context is canceled.
+	Infinite bool
+}
+
+// String converts the limits into the go command.
+func (l Limits) String() string {
+	var b strings.Builder
+	b.WriteString("go")
+
+	add := func(name string, value int64) {
+		if value > 0 {
+			fmt.Fprintf(&b, " %s %d", name, value)
+		}
+	}
+	add("depth", int64(l.Depth))
+	add("nodes", int64(l.Nodes))
+	add("movetime", l.MoveTime.Milliseconds())
+	add("wtime", l.WTime.Milliseconds())
+	add("btime", l.BTime.Milliseconds())
+	add("winc", l.WInc.Milliseconds())
+	add("binc", l.BInc.Milliseconds())
+	add("movestogo", int64(l.MovesToGo))
+	if l.Infinite {
+		b.WriteString(" infinite")
+	}
+	return b.String()
+}
+
+// Score is the evaluation reported by the engine from the perspective of the
+// active color.
+type Score struct {
+	// Value is measured in centipawns, or in moves until the checkmate if
+	// Mate is true.  Negative mate values mean the active color is getting
+	// checkmated.
+	Value int
+	Mate  bool
+	// Lower and Upper report whether the score is only a bound.
+	Lower, Upper bool
+}
+
+// Info is the search information reported by the engine.
+type Info struct {
+	Depth    int
+	SelDepth int
+	// MultiPV is the index of the line, starting from 1, if the engine
+	// reports multiple lines.
+	MultiPV int
+	Score   Score
+	Nodes   uint64
+	NPS     uint64
+	Time    time.Duration
+	// PV is the principal variation, starting with the best move.
+	PV []chego.Move
+}
+
+// Result is the outcome of the search.
+type Result struct {
+	// BestMove is zero if the position has no legal moves.
+	BestMove chego.Move
+	// Ponder is the expected reply to the best move.  Zero if not reported.
+	Ponder chego.Move
+	// Info is the last reported information with a score.
+	Info Info
+}
+
+// Search is the search started by [Engine.Go].
+type Search struct {
+	// Info receives the information with the score while the search is running.
+	// The channel is buffered and closed when the search finishes.  Info lines
+	// which don't fit into the buffer are discarded, since the engine must
+	// not wait for a slow reader.
+	Info <-chan Info
+
+	done   chan struct{}
+	result Result
+	err    error
+}
+
+// Wait waits until the search finishes and returns its result.
+func (s *Search) Wait() (Result, error) {
+	<-s.done
+	return s.result, s.err
+}
+
+// Go starts the search of the position set by [Engine.SetPosition].  When the
+// context is canceled, the stop command is sent and the engine is given
+// [Engine.StopTimeout] to report the best move; the result is returned as
+// usual.  [ErrTimeout] is returned if the engine doesn't stop in time, after
+// which the engine should be closed.
+func (e *Engine) Go(ctx context.Context, limits Limits) (*Search, error) {
+	if err := e.send(limits.String()); err != nil {
+		return nil, err
+	}
+
+	info := make(chan Info, 64)
+	s := &Search{Info: info, done: make(chan struct{})}
+
+	go func() {
+		defer close(s.done)
+		defer close(info)
+		s.result, s.err = e.wait(ctx, info)
+	}()
+	return s, nil
+}
+
+// wait reads the output of the engine until the best move.
+func (e *Engine) wait(ctx context.Context, info chan<- Info) (Result, error) {
+	var r Result
+	done := ctx.Done()
+	var timeout <-chan time.Time
+
+	for {
+		var line string
+		select {
+		case l, ok := <-e.lines:
+			if !ok {
+				return r, ErrExited
+			}
+			line = l
+		case <-done:
+			if err := e.send("stop"); err != nil {
+				return r, err
+			}
+			done, timeout = nil, time.After(e.StopTimeout)
+			continue
+		case <-timeout:
+			return r, ErrTimeout
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "info":
+			if !strings.Contains(line, " score ") {
+				continue
+			}
+			i, err := ParseInfo(line, &e.pos)
+			if err != nil {
+				continue
+			}
+			r.Info = i
+			select {
+			case info <- i:
+			default:
+			}
+
+		case "bestmove":
+			if len(fields) < 2 {
+				return r, fmt.Errorf("%w: %q", ErrInvalidInfo, line)
+			}
+			// Engines report "0000" or "(none)" in the terminal positions.
+			if fields[1] == "0000" || fields[1] == "(none)" {
+				return r, nil
+			}
+
+			m, err := chego.ParseUCIMove(fields[1], &e.pos)
+			if err != nil {
+				return r, err
+			}
+			r.BestMove = m
+
+			if len(fields) == 4 && fields[2] == "ponder" {
+				p := e.pos
+				p.MakeMove(m)
+				// The ponder move is optional, so the invalid one is
+				// ignored.
+				r.Ponder, _ = chego.ParseUCIMove(fields[3], &p)
+			}
+			return r, nil
+		}
+	}
+}
+
+// ParseInfo parses the info line reported by the engine in the specified
+// position.  The position is required to convert the principal variation into
+// moves.  Unknown tokens are skipped.
+//
+// Returns [ErrInvalidInfo] if the line is malformed and [chego.ErrIllegalMove]
+// if the principal variation contains an illegal move.
+func ParseInfo(line string, p *chego.Position) (Info, error) {
+	var i Info
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "info" {
+		return i, fmt.Errorf("%w: %q", ErrInvalidInfo, line)
+	}
+
+	// number parses the value of the field at index j.
+	number := func(j int) (int, error) {
+		if j >= len(fields) {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidInfo, line)
+		}
+		n, err := strconv.Atoi(fields[j])
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidInfo, line)
+		}
+		return n, nil
+	}
+
+	for j := 1; j < len(fields); j++ {
+		var n int
+		var err error
+
+		switch fields[j] {
+		case "depth":
+			n, err = number(j + 1)
+			i.Depth = n
+			j++
+		case "seldepth":
+			n, err = number(j + 1)
+			i.SelDepth = n
+			j++
+		case "multipv":
+			n, err = number(j + 1)
+			i.MultiPV = n
+			j++
+		case "nodes":
+			n, err = number(j + 1)
+			i.Nodes = uint64(n)
+			j++
+		case "nps":
+			n, err = number(j + 1)
+			i.NPS = uint64(n)
+			j++
+		case "time":
+			n, err = number(j + 1)
+			i.Time = time.Duration(n) * time.Millisecond
+			j++
+		case "score":
+			if j+1 == len(fields) ||
+				fields[j+1] != "cp" && fields[j+1] != "mate" {
+				return i, fmt.Errorf("%w: %q", ErrInvalidInfo, line)
+			}
+			i.Score.Mate = fields[j+1] == "mate"
+			n, err = number(j + 2)
+			i.Score.Value = n
+			j += 2
+			if j+1 < len(fields) {
+				switch fields[j+1] {
+				case "lowerbound":
+					i.Score.Lower = true
+					j++
+				case "upperbound":
+					i.Score.Upper = true
+					j++
+				}
+			}
+		case "pv":
+			pv, err := parsePV(fields[j+1:], p)
+			i.PV = pv
+			return i, err
+		case "string":
+			// The rest of the line is an arbitrary text.
+			return i, nil
+		}
+
+		if err != nil {
+			return i, err
+		}
+	}
+	return i, nil
+}
+
+// parsePV converts the moves into the principal variation, starting from the
+// specified position.
+func parsePV(moves []string, p *chego.Position) ([]chego.Move, error) {
+	pv := make([]chego.Move, 0, len(moves))
+	pos := *p
+
+	for _, s := range moves {
+		m, err := chego.ParseUCIMove(s, &pos)
+		if err != nil {
+			return pv, err
+		}
+		pv = append(pv, m)
+		pos.MakeMove(m)
+	}
+	return pv, nil
+}
@@ -0,0 +1,41 @@
+#!/bin/sh
+# Fake UCI engine used by the tests.  Search commands print the predefined
+# output regardless of the position.
+hang=false
+
+while read -r line; do
+	case "$line" in
+	uci)
+		echo "id name Fake Engine 1.0"
+		echo "id author chego"
+		echo "option name Hash type spin default 16 min 1 max 1024"
+		echo "option name Skill Level type combo default Normal var Easy var Normal"
+		echo "option name Clear Hash type button"
+		echo "uciok"
+		;;
+	isready)
+		echo "readyok"
+		;;
+	"setoption name Hang value true")
+		hang=true
+		;;
+	"go infinite")
+		echo "info depth 1 score cp 13 nodes 20 nps 20000 time 1 pv e2e4"
+		echo "info string waiting for stop"
+		;;
+	go*)
+		echo "info depth 1 seldepth 2 multipv 1 score cp 13 nodes 20 nps 20000 time 1 pv e2e4"
+		echo "info currmove e2e4 currmovenumber 1"
+		echo "info depth 2 score mate 3 lowerbound nodes 150 pv e2e4 e7e5"
+		echo "bestmove e2e4 ponder e7e5"
+		;;
+	stop)
+		if [ "$hang" = false ]; then
+			echo "bestmove d2d4"
+		fi
+		;;
+	quit)
+		exit 0
+		;;
+	esac
+done
@@ -0,0 +1,50 @@
+package chego
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseUCIMove(t *testing.T) {
+	cases := []struct {
+		uci      string
+		fen      string
+		expected Move
+		err      error
+	}{
+		{"e2e4", InitialPos, NewMove(SE4, SE2, MoveNormal), nil},
+		{"g1f3", InitialPos, NewMove(SF3, SG1, MoveNormal), nil},
+		{"e1g1", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", NewMove(SG1, SE1, MoveCastling), nil},
+		{"e8c8", "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", NewMove(SC8, SE8, MoveCastling), nil},
+		{"e5d6", "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", NewMove(SD6, SE5, MoveEnPassant), nil},
+		{"b7b8n", "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", NewPromotionMove(SB8, SB7, PromotionKnight), nil},
+		{"b7b8q", "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", NewPromotionMove(SB8, SB7, PromotionQueen), nil},
+		{"b7b8", "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1", 0, ErrIllegalMove},
+		{"e2e5", InitialPos, 0, ErrIllegalMove},
+		{"e2e4q", InitialPos, 0, ErrIllegalMove},
+		{"e2e4k", InitialPos, 0, ErrInvalidUCI},
+		{"0000", InitialPos, 0, ErrInvalidUCI},
+		{"e2", InitialPos, 0, ErrInvalidUCI},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseUCIMove(tc.uci, ParseFen(tc.fen))
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%s: expected error %v, got %v", tc.uci, tc.err, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("%s: expected %d, got %d", tc.uci, tc.expected, got)
+		}
+		if err == nil && got.UCI() != tc.uci {
+			t.Fatalf("expected %s, got %s", tc.uci, got.UCI())
+		}
+	}
+}
+
+func BenchmarkMoveUCI(b *testing.B) {
+	m := NewPromotionMove(SB8, SB7, PromotionKnight)
+
+	for b.Loop() {
+		_ = m.UCI()
+	}
+}